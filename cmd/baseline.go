@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+var baselineTarget string
+
+// baselineCmd represents the baseline command.
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Record migrations of existing database as applied",
+	Long: `Create migrations log in existing database and record migrations up to the target
+migration inclusive as migrated without applying them. Use it for databases whose schema
+already contains changes of these migrations, then up applies only migrations after the target.
+Baselined migrations are marked in status command output.
+Use --dry-run to print migrations which would be recorded without recording them.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandBaseline, []migratorApp.RunOption{migratorApp.WithTarget(baselineTarget)})
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+		reporter := newReporter()
+
+		result, err := app.Baseline(cmd.Context(), baselineTarget)
+		reportErr := reporter.ReportRun(result)
+		if err != nil {
+			fatalRun(app, "baseline migrations", err)
+		}
+		if reportErr != nil {
+			fatalf(app, "report baseline migrations: %v", reportErr)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(baselineCmd)
+	addOutputFlag(baselineCmd)
+	addLockFlags(baselineCmd)
+	addChangesDryRunFlag(baselineCmd)
+
+	baselineCmd.Flags().StringVar(&baselineTarget, "to", "", "Target migration file name or datetime to baseline migrations up to")
+	baselineCmd.MarkFlagRequired("to")
+}
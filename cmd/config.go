@@ -1,6 +1,42 @@
 package cmd
 
+import (
+	"log"
+	"log/slog"
+	"time"
+
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
 type Config struct {
 	MigrationsDir   string `mapstructure:"migrations_dir"`
 	DBConnectionDSN string `mapstructure:"db_dsn"`
+	DBType          string `mapstructure:"db_type"`
+	HistorySchema   string `mapstructure:"history_schema"`
+	HistoryTable    string `mapstructure:"history_table"`
+	// LockTimeout limits waiting for run lock held by another migrator, zero waits forever.
+	LockTimeout time.Duration `mapstructure:"lock_timeout"`
+}
+
+// newMigratorApp returns application configured with appConfig.
+func newMigratorApp() *migratorApp.MigratorApp {
+	app := migratorApp.New(
+		appConfig.MigrationsDir,
+		appConfig.DBConnectionDSN,
+		migratorApp.DBType(appConfig.DBType),
+	)
+	app.HistorySchema = appConfig.HistorySchema
+	app.HistoryTable = appConfig.HistoryTable
+	app.LockTimeout = appConfig.LockTimeout
+	app.Logger = slog.Default()
+	app.ToolVersion = release
+
+	return app
+}
+
+// fatalf logs error like log.Fatalf, closing database connection of app before exit,
+// since os.Exit skips deferred calls.
+func fatalf(app *migratorApp.MigratorApp, format string, v ...any) {
+	app.Close()
+	log.Fatalf(format, v...)
 }
@@ -8,7 +8,7 @@ import (
 	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
-// var goFlag bool
+var goFlag bool
 
 var createCmd = &cobra.Command{
 	Use:   "create <migration-name>",
@@ -27,20 +27,17 @@ var createCmd = &cobra.Command{
 		}
 		migrationName = args[0]
 
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
+		app := newMigratorApp()
+		defer app.Close()
 
 		format := migratorApp.MigrationFormatSQL
-		// if goFlag {
-		// 	format = migratorApp.MigrationFormatGo
-		// }
+		if goFlag {
+			format = migratorApp.MigrationFormatGo
+		}
 
 		createdFile, err := app.CreateMigration(migrationName, format)
 		if err != nil {
-			log.Fatalf("create migration: %v", err)
+			fatalf(app, "create migration: %v", err)
 		}
 		log.Println("Migration file created: ", createdFile.Name())
 	},
@@ -49,13 +46,5 @@ var createCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(createCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// createCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// createCmd.Flags().BoolVar(&goFlag, "go", false, "Go format for migration file")
+	createCmd.Flags().BoolVar(&goFlag, "go", false, "Go format for migration file")
 }
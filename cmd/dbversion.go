@@ -4,32 +4,33 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
-
 	"github.com/spf13/cobra"
-	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
 // dbversionCmd represents the dbversion command.
 var dbversionCmd = &cobra.Command{
 	Use:   "dbversion",
 	Short: "Get current database version",
-	Run: func(_ *cobra.Command, _ []string) {
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
-		dbVersion, err := app.GetDBVersion()
+	Run: func(cmd *cobra.Command, _ []string) {
+		app := newMigratorApp()
+		defer app.Close()
+		reporter := newReporter()
+
+		dbVersion, err := app.GetDBVersion(cmd.Context())
+		if err != nil {
+			fatalf(app, "error while get databse version: %v", err)
+		}
+
+		err = reporter.ReportDBVersion(dbVersion)
 		if err != nil {
-			log.Fatalf("error while get databse version: %v", err)
+			fatalf(app, "error while report databse version: %v", err)
 		}
-		log.Println("Version:", dbVersion)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(dbversionCmd)
+	addOutputFlag(dbversionCmd)
 
 	// Here you will define your flags and configuration settings.
 
@@ -4,39 +4,66 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
-
 	"github.com/spf13/cobra"
 	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
+var (
+	downTarget string
+	downSteps  int
+	downAll    bool
+)
+
 // downCmd represents the down command.
 var downCmd = &cobra.Command{
 	Use:   "down",
 	Short: "Rollback last success migration",
-	Run: func(_ *cobra.Command, _ []string) {
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
-		err := app.Down()
+	Long: `Rollback last success migration.
+Use --steps to rollback N last migrations, --to to rollback all migrations applied after the target one
+or --all to rollback all applied migrations. Migrations are rolled back in reverse order of applying,
+rollback stops on the first failure.
+Use --dry-run to print migrations and their SQL without rolling them back.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		opts := []migratorApp.RunOption{}
+		if downTarget != "" {
+			opts = append(opts, migratorApp.WithTarget(downTarget))
+		}
+		if downSteps != 0 {
+			opts = append(opts, migratorApp.WithSteps(downSteps))
+		}
+		if downAll {
+			opts = append(opts, migratorApp.WithAll())
+		}
+
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandDown, opts)
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+		reporter := newReporter()
+
+		result, err := app.Down(cmd.Context(), opts...)
+		reportErr := reporter.ReportRun(result)
 		if err != nil {
-			log.Fatalf("down migrations: %v", err)
+			fatalRun(app, "down migrations", err)
+		}
+		if reportErr != nil {
+			fatalf(app, "report down migrations: %v", reportErr)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(downCmd)
+	addOutputFlag(downCmd)
+	addLockFlags(downCmd)
+	addDryRunFlag(downCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// downCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// downCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	downCmd.Flags().StringVar(&downTarget, "to", "", "Target migration file name or datetime to rollback migrations down to")
+	downCmd.Flags().IntVar(&downSteps, "steps", 0, "Count of migrations to rollback")
+	downCmd.Flags().BoolVar(&downAll, "all", false, "Rollback all applied migrations")
+	downCmd.MarkFlagsMutuallyExclusive("to", "steps", "all")
 }
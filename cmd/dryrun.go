@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+var dryRun bool
+
+// addDryRunFlag adds --dry-run flag, which prints plan of command instead of running it.
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		"Print migrations and SQL which would be run, without changing database",
+	)
+}
+
+// addChangesDryRunFlag adds --dry-run flag to commands which only change records of migrations log.
+func addChangesDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&dryRun,
+		"dry-run",
+		false,
+		"Print status and checksum changes which would be made, without changing database",
+	)
+}
+
+// reportPlan writes plan of command with run options to stdout.
+func reportPlan(cmd *cobra.Command, command migratorApp.Command, opts []migratorApp.RunOption) {
+	app := newMigratorApp()
+	defer app.Close()
+	app.AllowChecksumMismatch = allowChecksumMismatch
+	reporter := newReporter()
+
+	plan, err := app.Plan(cmd.Context(), command, opts...)
+	if err != nil {
+		fatalf(app, "plan %s migrations: %v", command, err)
+	}
+
+	err = reporter.ReportPlan(plan)
+	if err != nil {
+		fatalf(app, "report plan of %s migrations: %v", command, err)
+	}
+}
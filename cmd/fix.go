@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+var fixStatus string
+
+// fixCmd represents the fix command.
+var fixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Reset orphaned migrations",
+	Long: `Reset status of orphaned migrations, i.e. migrations left in migrating status by stopped migrator.
+Orphaned migrations are marked in status command output. They get failed status by default,
+use --status=new if changes of migrations were rolled back, e.g. by transaction.
+Use --dry-run to print migrations which would be reset without changing them,
+--output sets format of the printed plan.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandFix, []migratorApp.RunOption{
+				migratorApp.WithStatus(migratorConstants.MigrationStatus(fixStatus)),
+			})
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+
+		fixed, err := app.Fix(cmd.Context(), migratorConstants.MigrationStatus(fixStatus))
+		if err != nil {
+			fatalRun(app, "fix orphaned migrations", err)
+		}
+
+		for i := range fixed {
+			log.Printf("Status reset to %s: %s", fixed[i].Status, fixed[i].Name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+	addLockFlags(fixCmd)
+	addOutputFlag(fixCmd)
+	addChangesDryRunFlag(fixCmd)
+
+	fixCmd.Flags().StringVar(
+		&fixStatus,
+		"status",
+		string(migratorConstants.MigrationStatusFailed),
+		"Status of orphaned migrations (failed|new)",
+	)
+}
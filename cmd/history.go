@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyMigration string
+	historySince     string
+)
+
+// historyCmd represents the history command.
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Migrations event log",
+	Long: `Show events of migrations event log: every applying, rollback and status change of migrations
+with user, host and version of migrator, duration and error. Events are shown in order they were recorded.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		since, err := parseSince(historySince, time.Now())
+		if err != nil {
+			log.Fatalf("error while parse --since: %v", err)
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		reporter := newReporter()
+
+		events, err := app.History(cmd.Context(), historyMigration, since)
+		if err != nil {
+			fatalf(app, "error while get migrations history: %v", err)
+		}
+
+		err = reporter.ReportHistory(events)
+		if err != nil {
+			fatalf(app, "error while report migrations history: %v", err)
+		}
+	},
+}
+
+// parseSince parses time in RFC 3339 format, date and time, date or duration before now, e.g. "24h".
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if since, err := time.Parse(layout, value); err == nil {
+			return since, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 time, date or duration like 24h", value)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	addOutputFlag(historyCmd)
+
+	historyCmd.Flags().StringVar(
+		&historyMigration,
+		"migration",
+		"",
+		"Show events of migration with this file name or timestamp only",
+	)
+	historyCmd.Flags().StringVar(
+		&historySince,
+		"since",
+		"",
+		"Show events started at this time or later: RFC 3339 time, date and time in UTC, date or duration before now, e.g. 24h",
+	)
+}
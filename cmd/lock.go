@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+// exitCodeLocked is exit code of up, down and redo if run lock is held by another migrator,
+// so deploy pipelines can retry instead of treating it as a failed migration.
+const exitCodeLocked = 3
+
+var (
+	lockTimeout time.Duration
+	noWait      bool
+)
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(
+		&lockTimeout,
+		"lock-timeout",
+		0,
+		"Time to wait for another migrator to finish, e.g. 30s (default is to wait without limit)",
+	)
+	cmd.Flags().BoolVar(&noWait, "no-wait", false, "Fail at once if another migrator is running")
+	cmd.MarkFlagsMutuallyExclusive("lock-timeout", "no-wait")
+}
+
+// setLockOptions sets run lock options of app, --lock-timeout flag overrides lock_timeout config option.
+func setLockOptions(cmd *cobra.Command, app *migratorApp.MigratorApp) {
+	if cmd.Flags().Changed("lock-timeout") {
+		app.LockTimeout = lockTimeout
+	}
+	app.NoWait = noWait
+}
+
+// fatalRun logs error of command running migrations, closes app and exits,
+// exit code is exitCodeLocked if run lock wasn't acquired.
+func fatalRun(app *migratorApp.MigratorApp, command string, err error) {
+	if errors.Is(err, migratorApp.ErrLockNotAcquired) {
+		log.Printf("%s: %v", command, err)
+		app.Close()
+		os.Exit(exitCodeLocked)
+	}
+
+	fatalf(app, "%s: %v", command, err)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+var markStatus string
+
+// markCmd represents the mark command.
+var markCmd = &cobra.Command{
+	Use:     "mark <migration>",
+	Aliases: []string{"force"},
+	Short:   "Set status of migration without applying it",
+	Long: `Set status of migration without applying or rolling it back, e.g. after migration was applied
+by hand or partially applied without transaction. Status is changed under the lock of migration
+and recorded in migrations event log with mark direction.
+Migration is identified by file name with or without extension or by its timestamp.
+Use --dry-run to print the status change without making it, --output sets format of the printed plan.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandMark, []migratorApp.RunOption{
+				migratorApp.WithTarget(args[0]),
+				migratorApp.WithStatus(migratorConstants.MigrationStatus(markStatus)),
+			})
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+
+		migration, err := app.Mark(cmd.Context(), args[0], migratorConstants.MigrationStatus(markStatus))
+		if err != nil {
+			fatalRun(app, "mark migration", err)
+		}
+
+		log.Printf("Status set to %s: %s", migration.Status, migration.Name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(markCmd)
+	addLockFlags(markCmd)
+	addOutputFlag(markCmd)
+	addChangesDryRunFlag(markCmd)
+
+	markCmd.Flags().StringVar(&markStatus, "status", "", "Status of migration (migrated|new|failed)")
+	markCmd.MarkFlagRequired("status")
+}
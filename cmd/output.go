@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+var outputFormat string
+
+func addOutputFlag(cmd *cobra.Command) {
+	formats := make([]string, len(migratorApp.OutputFormats))
+	for i := range migratorApp.OutputFormats {
+		formats[i] = string(migratorApp.OutputFormats[i])
+	}
+
+	cmd.Flags().StringVarP(
+		&outputFormat,
+		"output",
+		"o",
+		string(migratorApp.OutputFormatPlain),
+		fmt.Sprintf("Output format (%s)", strings.Join(formats, "|")),
+	)
+}
+
+// newReporter returns reporter writing command results to stdout.
+// Logs are written to stderr, so stdout contains results only.
+func newReporter() migratorApp.Reporter {
+	reporter, err := migratorApp.NewReporter(migratorApp.OutputFormat(outputFormat), os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return reporter
+}
@@ -4,8 +4,6 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
-
 	"github.com/spf13/cobra"
 	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
@@ -14,22 +12,36 @@ import (
 var redoCmd = &cobra.Command{
 	Use:   "redo",
 	Short: "Redo last success migration",
-	Run: func(_ *cobra.Command, _ []string) {
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
-
-		err := app.Redo()
+	Long: `Rollback and apply again last success migration.
+Use --dry-run to print the migration and its SQL without running it.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandRedo, nil)
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+
+		reporter := newReporter()
+
+		result, err := app.Redo(cmd.Context())
+		reportErr := reporter.ReportRun(result)
 		if err != nil {
-			log.Fatalf("redo last migration: %v", err)
+			fatalRun(app, "redo last migration", err)
+		}
+		if reportErr != nil {
+			fatalf(app, "report redo last migration: %v", reportErr)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(redoCmd)
+	addOutputFlag(redoCmd)
+	addLockFlags(redoCmd)
+	addDryRunFlag(redoCmd)
 
 	// Here you will define your flags and configuration settings.
 
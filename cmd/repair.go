@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+var allowChecksumMismatch bool
+
+func addAllowChecksumMismatchFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&allowChecksumMismatch,
+		"allow-checksum-mismatch",
+		false,
+		"Report changed files of applied migrations as warnings instead of errors",
+	)
+}
+
+// repairCmd represents the repair command.
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Record checksums of changed applied migrations",
+	Long: `Record current checksums of applied migration files.
+Use it after deliberate edit of applied migrations to stop reporting checksum mismatches.
+Use --dry-run to print checksums which would be updated without recording them,
+--output sets format of the printed plan.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandRepair, nil)
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+
+		repaired, err := app.Repair(cmd.Context())
+		if err != nil {
+			fatalf(app, "repair migration checksums: %v", err)
+		}
+
+		for i := range repaired {
+			log.Println("Checksum updated:", repaired[i].Name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+	addOutputFlag(repairCmd)
+	addChangesDryRunFlag(repairCmd)
+}
@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
 var (
@@ -31,7 +36,11 @@ to quickly create a Cobra application.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Миграция прерывается по SIGINT и SIGTERM, транзакция текущей миграции откатывается.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -46,6 +55,17 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (YAML format)")
 	rootCmd.PersistentFlags().String("migrations-dir", "", "Directory with migration files")
 	rootCmd.PersistentFlags().String("db-dsn", "", "Database connection in DSN format")
+	rootCmd.PersistentFlags().String(
+		"db-type",
+		"",
+		fmt.Sprintf("Database type, one of %v (default is detected by DSN scheme)", migratorApp.DBTypes()),
+	)
+	rootCmd.PersistentFlags().String(
+		"history-schema",
+		"",
+		"Schema of migrations log table (default \"public\" for PostgreSQL, connection database for MySQL, \"main\" for SQLite)",
+	)
+	rootCmd.PersistentFlags().String("history-table", "dbmigrations", "Name of migrations log table")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -61,6 +81,8 @@ func initConfig() {
 	viper.SetEnvPrefix("gomigrator")
 	viper.AllowEmptyEnv(true)
 	viper.AutomaticEnv()
+	// Ключ без флага должен быть известен viper, чтобы читаться из переменной окружения.
+	viper.SetDefault("lock_timeout", 0)
 
 	if cfgFile != "" {
 		if err := viper.ReadInConfig(); err != nil {
@@ -78,6 +100,21 @@ func initConfig() {
 		log.Fatal("error binding flag:", err)
 	}
 
+	err = viper.BindPFlag("db_type", rootCmd.PersistentFlags().Lookup("db-type"))
+	if err != nil {
+		log.Fatal("error binding flag:", err)
+	}
+
+	err = viper.BindPFlag("history_schema", rootCmd.PersistentFlags().Lookup("history-schema"))
+	if err != nil {
+		log.Fatal("error binding flag:", err)
+	}
+
+	err = viper.BindPFlag("history_table", rootCmd.PersistentFlags().Lookup("history-table"))
+	if err != nil {
+		log.Fatal("error binding flag:", err)
+	}
+
 	if err := viper.Unmarshal(&appConfig); err != nil {
 		log.Fatal("error parsing config into struct:", err)
 	}
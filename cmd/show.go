@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// showCmd represents the show command.
+var showCmd = &cobra.Command{
+	Use:   "show <migration>",
+	Short: "Show details of migration",
+	Long: `Show status of migration and details of its last applying: duration,
+error message, error code (SQLSTATE) and number of the failed statement.
+Migration is identified by file name with or without extension or by its timestamp.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		app := newMigratorApp()
+		defer app.Close()
+		reporter := newReporter()
+
+		migration, err := app.Show(cmd.Context(), args[0])
+		if err != nil {
+			fatalf(app, "error while get migration: %v", err)
+		}
+
+		err = reporter.ReportMigration(migration)
+		if err != nil {
+			fatalf(app, "error while report migration: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+	addOutputFlag(showCmd)
+}
@@ -4,36 +4,37 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
-	"time"
-
 	"github.com/spf13/cobra"
-	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
 // statusCmd represents the status command.
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Migrations status table",
-	Run: func(_ *cobra.Command, _ []string) {
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
-		migrations, err := app.Status()
+	Long: `Show statuses of all migrations: applied to database and found in migrations dir.
+Migrations never applied to database are shown with status "new".`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		app := newMigratorApp()
+		defer app.Close()
+		app.AllowChecksumMismatch = allowChecksumMismatch
+		reporter := newReporter()
+
+		migrations, err := app.Status(cmd.Context())
 		if err != nil {
-			log.Fatalf("error while get migration statuses: %v", err)
+			fatalf(app, "error while get migration statuses: %v", err)
 		}
 
-		for i := range migrations {
-			log.Printf("%s | %s | %s", migrations[i].Status, migrations[i].MigrateDT.Format(time.DateTime), migrations[i].Name)
+		err = reporter.ReportStatus(migrations)
+		if err != nil {
+			fatalf(app, "error while report migration statuses: %v", err)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	addOutputFlag(statusCmd)
+	addAllowChecksumMismatchFlag(statusCmd)
 
 	// Here you will define your flags and configuration settings.
 
@@ -1,39 +1,62 @@
 package cmd
 
 import (
-	"log"
-
 	"github.com/spf13/cobra"
 	migratorApp "github.com/wursta/gomigrator/pkg/app"
 )
 
+var (
+	upTarget string
+	upSteps  int
+)
+
 // upCmd represents the up command.
 var upCmd = &cobra.Command{
 	Use:   "up",
 	Short: "Apply new migrations",
-	Run: func(_ *cobra.Command, _ []string) {
-		app := migratorApp.New(
-			appConfig.MigrationsDir,
-			appConfig.DBConnectionDSN,
-			migratorApp.DBTypePotgreSQL,
-		)
-		err := app.Up()
+	Long: `Apply new migrations in order of their creation datetime.
+Use --to to apply migrations up to the target migration inclusive
+or --steps to apply only N next migrations.
+Use --dry-run to print migrations and their SQL without applying them.`,
+	Run: func(cmd *cobra.Command, _ []string) {
+		opts := []migratorApp.RunOption{}
+		if upTarget != "" {
+			opts = append(opts, migratorApp.WithTarget(upTarget))
+		}
+		if upSteps != 0 {
+			opts = append(opts, migratorApp.WithSteps(upSteps))
+		}
+
+		if dryRun {
+			reportPlan(cmd, migratorApp.CommandUp, opts)
+			return
+		}
+
+		app := newMigratorApp()
+		defer app.Close()
+		setLockOptions(cmd, app)
+		app.AllowChecksumMismatch = allowChecksumMismatch
+		reporter := newReporter()
+
+		result, err := app.Up(cmd.Context(), opts...)
+		reportErr := reporter.ReportRun(result)
 		if err != nil {
-			log.Fatalf("up migrations: %v", err)
+			fatalRun(app, "up migrations", err)
+		}
+		if reportErr != nil {
+			fatalf(app, "report up migrations: %v", reportErr)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(upCmd)
+	addOutputFlag(upCmd)
+	addLockFlags(upCmd)
+	addAllowChecksumMismatchFlag(upCmd)
+	addDryRunFlag(upCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// upCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// upCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	upCmd.Flags().StringVar(&upTarget, "to", "", "Target migration file name or datetime to apply migrations up to")
+	upCmd.Flags().IntVar(&upSteps, "steps", 0, "Count of migrations to apply")
+	upCmd.MarkFlagsMutuallyExclusive("to", "steps")
 }
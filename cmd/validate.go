@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command.
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate migration files",
+	Long: `Validate migration files without connecting to database:
+file names, "-- migration: up" and "-- migration: down" markers,
+non-empty up sections and uniqueness of migration datetimes.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		app := newMigratorApp()
+		defer app.Close()
+
+		validationErrors, err := app.Validate()
+		if err != nil {
+			fatalf(app, "validate migrations: %v", err)
+		}
+
+		for i := range validationErrors {
+			log.Println("Invalid migration:", validationErrors[i])
+		}
+
+		if len(validationErrors) != 0 {
+			fatalf(app, "validate migrations: %d problems found", len(validationErrors))
+		}
+
+		log.Println("Migrations are valid")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
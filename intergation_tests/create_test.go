@@ -74,6 +74,38 @@ func TestCreateSuccess(t *testing.T) {
 	}
 }
 
+func TestCreateGoSuccess(t *testing.T) {
+	err := createMigrationsDir("migrations_go_test", 0o755)
+	if err != nil {
+		t.Error(err)
+	}
+	defer clearMigrationsDir("migrations_go_test")
+
+	returnCode, stdOut, stdErr, err := execCmd(
+		nil,
+		"create",
+		"backfill_foo_table",
+		"--go",
+		"--migrations-dir=./migrations_go_test",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+
+	require.Equal(t, "", stdOut.String())
+
+	re := regexp.MustCompile("^" + GetGoMigrationFileCreatedPattern("migrations_go_test", "backfill_foo_table"))
+	require.Regexp(t, re, stdErr.String())
+
+	content, err := os.ReadFile(re.FindStringSubmatch(stdErr.String())[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Contains(t, string(content), "package migrations_go_test")
+	require.Contains(t, string(content), "migratorApp.AddMigration(")
+}
+
 func isCreatedFileExits(output, migrationsDir, migrationName string) bool {
 	re := regexp.MustCompile(GetMigrationFileCreatedPattern(migrationsDir, migrationName))
 	match := re.FindStringSubmatch(output)
@@ -2,7 +2,6 @@ package intergationtests
 
 import (
 	"fmt"
-	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -32,9 +31,7 @@ func TestDBVersionSuccess(t *testing.T) {
 	}
 	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-	require.Equal(t, "", stdOut.String())
-	outputRegex := regexp.MustCompile(`\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} Version: 2`)
-	require.Regexp(t, outputRegex, stdErr.String())
+	require.Equal(t, "Version: 2\n", stdOut.String())
 
 	execCmd(nil, "down", "--config=./configs/up_config.yaml")
 
@@ -44,7 +41,5 @@ func TestDBVersionSuccess(t *testing.T) {
 	}
 	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-	require.Equal(t, "", stdOut.String())
-	outputRegex = regexp.MustCompile(`\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} Version: 1`)
-	require.Regexp(t, outputRegex, stdErr.String())
+	require.Equal(t, "Version: 1\n", stdOut.String())
 }
@@ -67,7 +67,11 @@ func TestDownSuccess(t *testing.T) {
 				t.Fatal(err)
 			}
 			require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
-			require.Equal(t, "", stdOut.String())
+			require.Equal(
+				t,
+				"down | new | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n",
+				stdOut.String(),
+			)
 
 			outputRegex := regexp.MustCompile(GetRollbackStepPattern(
 				"Start",
@@ -108,7 +112,7 @@ func TestDownSuccess(t *testing.T) {
 				t.Fatal(err)
 			}
 			require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
-			require.Equal(t, "", stdOut.String())
+			require.Equal(t, "down | new | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n", stdOut.String())
 
 			outputRegex = regexp.MustCompile(GetRollbackStepPattern(
 				"Start",
@@ -135,6 +139,39 @@ func TestDownSuccess(t *testing.T) {
 	}
 }
 
+func TestDownAll(t *testing.T) {
+	err := CreateDatabase("migrator_down_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DropDatabase("migrator_down_test")
+
+	db, err := Connect("postgres://test:test@db:5432/migrator_down_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = initDBTestData(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returnCode, stdOut, stdErr, err := execCmd(nil, "down", "--all", "--config=./configs/down_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Equal(
+		t,
+		"down | new | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n"+
+			"down | new | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n",
+		stdOut.String(),
+	)
+
+	checkTable(t, db, false, false)
+}
+
 func checkTable(t *testing.T, db *sqlx.DB, expectedTableExists, expectedColumnExists bool) {
 	t.Helper()
 
@@ -70,7 +70,12 @@ func TestRedoSuccess(t *testing.T) {
 			}
 			require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-			require.Equal(t, "", stdOut.String())
+			require.Equal(
+				t,
+				"down | new | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n"+
+					"up | migrated | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n",
+				stdOut.String(),
+			)
 
 			outputRegex := regexp.MustCompile(GetRollbackStepPattern(
 				"Start",
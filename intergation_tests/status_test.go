@@ -32,9 +32,7 @@ func TestStatusSuccess(t *testing.T) {
 	}
 	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-	require.Equal(t, "", stdOut.String())
-
-	outputRegex := regexp.MustCompile(GetMigrationStatusPattern(
+	outputRegex := regexp.MustCompile("^" + GetMigrationStatusPattern(
 		"migrated",
 		"2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
 	) + "\n" +
@@ -42,7 +40,7 @@ func TestStatusSuccess(t *testing.T) {
 			"migrated",
 			"2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
 		))
-	require.Regexp(t, outputRegex, stdErr)
+	require.Regexp(t, outputRegex, stdOut)
 
 	_, _, _, err = execCmd(nil, "down", "--config=./configs/up_config.yaml")
 	if err != nil {
@@ -55,7 +53,7 @@ func TestStatusSuccess(t *testing.T) {
 	}
 	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-	outputRegex = regexp.MustCompile(GetMigrationStatusPattern(
+	outputRegex = regexp.MustCompile("^" + GetMigrationStatusPattern(
 		"new",
 		"2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
 	) + "\n" +
@@ -63,5 +61,27 @@ func TestStatusSuccess(t *testing.T) {
 			"migrated",
 			"2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
 		))
-	require.Regexp(t, outputRegex, stdErr)
+	require.Regexp(t, outputRegex, stdOut)
+}
+
+func TestStatusPending(t *testing.T) {
+	err := CreateDatabase("migrator_up_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DropDatabase("migrator_up_test")
+
+	returnCode, stdOut, stdErr, err := execCmd(nil, "status", "--config=./configs/up_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+
+	outputRegex := regexp.MustCompile("^" + GetPendingMigrationStatusPattern(
+		"2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
+	) + "\n" +
+		GetPendingMigrationStatusPattern(
+			"2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+		))
+	require.Regexp(t, outputRegex, stdOut)
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"regexp"
 )
 
 func GetUsingConfigFilePattern(configFilePath string) string {
@@ -17,16 +18,30 @@ func GetMigrationFileCreatedPattern(migrationsDir, migrationName string) string
 		`/\d{4}_\d{2}_\d{2}T\d{2}_\d{2}_\d{2}__` + migrationName + `__[a-zA-Z]{5}.sql)`
 }
 
+func GetGoMigrationFileCreatedPattern(migrationsDir, migrationName string) string {
+	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} Migration file created: .+(?P<FILENAME>` +
+		migrationsDir +
+		`/\d{4}_\d{2}_\d{2}T\d{2}_\d{2}_\d{2}__` + migrationName + `__[a-zA-Z]{5}.go)`
+}
+
 func GetMigrationStepPattern(stepName, migrationName string) string {
-	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} ` + stepName + ` migration: ` + migrationName
+	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} INFO ` + stepName + ` migration migration=` + migrationName
 }
 
 func GetRollbackStepPattern(stepName, migrationName string) string {
-	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} ` + stepName + ` rollback: ` + migrationName
+	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} INFO ` + stepName + ` rollback migration=` + migrationName
 }
 
 func GetMigrationStatusPattern(status, migrationName string) string {
-	return `\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} ` + status + ` | \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}: | ` + migrationName
+	return status + ` \| \d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2} \| ` + regexp.QuoteMeta(migrationName)
+}
+
+func GetPendingMigrationStatusPattern(migrationName string) string {
+	return `new \| - \| ` + regexp.QuoteMeta(migrationName)
+}
+
+func GetRunStepPattern(direction, status, migrationName string) string {
+	return direction + ` \| ` + status + ` \| ` + regexp.QuoteMeta(migrationName)
 }
 
 func runCmd(env map[string]string, args ...string) (cmd *exec.Cmd, stdOut *bytes.Buffer, stdErr *bytes.Buffer) {
@@ -64,7 +64,10 @@ func TestUpSuccess(t *testing.T) {
 			}
 			require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
 
-			require.Equal(t, "", stdOut.String())
+			require.Regexp(t, regexp.MustCompile("^"+
+				GetRunStepPattern("up", "migrated", "2024_07_05T18_51_07__create_table_foo__hKnRd.sql")+"\n"+
+				GetRunStepPattern("up", "migrated", "2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql")+"\n$",
+			), stdOut.String())
 
 			outputRegex := regexp.MustCompile(GetMigrationStepPattern(
 				"Start",
@@ -187,6 +190,119 @@ func TestUpConcurrent(t *testing.T) {
 			results[i].returnCode,
 			fmt.Sprintf("stdout: %s\nstderr: %s", results[i].stdOut, results[i].stdErr),
 		)
-		require.Equal(t, "", results[i].stdOut.String())
 	}
+
+	// Каждая миграция должна быть применена только одним из процессов.
+	appliedRegex := regexp.MustCompile(`up \| migrated \| (.+)\n`)
+	applied := map[string]int{}
+	for i := range results {
+		for _, match := range appliedRegex.FindAllStringSubmatch(results[i].stdOut.String(), -1) {
+			applied[match[1]]++
+		}
+	}
+	require.Equal(t, map[string]int{
+		"2024_07_05T18_51_07__create_table_foo__hKnRd.sql":                1,
+		"2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql": 1,
+	}, applied)
+}
+
+func TestUpStepsAndTarget(t *testing.T) {
+	err := CreateDatabase("migrator_up_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DropDatabase("migrator_up_test")
+
+	db, err := Connect("postgres://test:test@db:5432/migrator_up_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	returnCode, stdOut, stdErr, err := execCmd(nil, "up", "--steps=1", "--config=./configs/up_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Equal(t, "up | migrated | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n", stdOut.String())
+
+	returnCode, stdOut, stdErr, err = execCmd(nil, "up", "--to=2024_07_05T18_51_07", "--config=./configs/up_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.NotEqual(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Contains(t, stdErr.String(), "migration already applied")
+
+	returnCode, stdOut, stdErr, err = execCmd(nil, "up", "--to=2024_12_31T00_00_00", "--config=./configs/up_config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.NotEqual(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Contains(t, stdErr.String(), "unknown migration")
+
+	returnCode, stdOut, stdErr, err = execCmd(
+		nil,
+		"up",
+		"--to=2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB",
+		"--config=./configs/up_config.yaml",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Equal(t, "up | migrated | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n", stdOut.String())
+
+	checkTable(t, db, true, true)
+}
+
+func TestUpCustomHistoryTable(t *testing.T) {
+	err := CreateDatabase("migrator_up_history_table_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DropDatabase("migrator_up_history_table_test")
+
+	db, err := Connect("postgres://test:test@db:5432/migrator_up_history_table_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	returnCode, stdOut, stdErr, err := execCmd(
+		map[string]string{"GOMIGRATOR_HISTORY_TABLE": "migrations"},
+		"up",
+		"--migrations-dir=./migrations_up",
+		"--db-dsn=postgres://test:test@db:5432/migrator_up_history_table_test",
+		"--history-schema=service_foo",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+
+	historyTableExists, err := IsTableExists(db, "service_foo", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.True(t, historyTableExists)
+
+	defaultHistoryTableExists, err := IsTableExists(db, "public", "dbmigrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.False(t, defaultHistoryTableExists)
+
+	returnCode, stdOut, stdErr, err = execCmd(
+		nil,
+		"dbversion",
+		"--migrations-dir=./migrations_up",
+		"--db-dsn=postgres://test:test@db:5432/migrator_up_history_table_test",
+		"--history-schema=service_foo",
+		"--history-table=migrations",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Equal(t, "Version: 2\n", stdOut.String())
 }
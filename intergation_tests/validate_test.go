@@ -0,0 +1,44 @@
+package intergationtests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSuccess(t *testing.T) {
+	returnCode, stdOut, stdErr, err := execCmd(nil, "validate", "--migrations-dir=./migrations_up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 0, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Regexp(t, regexp.MustCompile(`\d{4}\/\d{2}\/\d{2} \d{2}:\d{2}:\d{2} Migrations are valid`), stdErr.String())
+}
+
+func TestValidateFailure(t *testing.T) {
+	err := createMigrationsDir("migrations_validate_test", 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clearMigrationsDir("migrations_validate_test")
+
+	err = os.WriteFile(
+		filepath.Join("migrations_validate_test", "2024_07_05T18_51_07__create_table_foo__hKnRd.sql"),
+		[]byte("CREATE TABLE public.foo(id SERIAL);"),
+		0o600,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	returnCode, stdOut, stdErr, err := execCmd(nil, "validate", "--migrations-dir=./migrations_validate_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	require.Equal(t, 1, returnCode, fmt.Sprintf("stdout: %s\nstderr: %s", stdOut, stdErr))
+	require.Contains(t, stdErr.String(), "Invalid migration: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql")
+}
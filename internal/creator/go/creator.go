@@ -0,0 +1,115 @@
+package creatorgo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/wursta/gomigrator/internal/utils"
+)
+
+const defaultPackageName = "migrations"
+
+var migrationTemplate = template.Must(template.New("migration").Parse(`package {{.PackageName}}
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	migratorApp "github.com/wursta/gomigrator/pkg/app"
+)
+
+func init() {
+	migratorApp.AddMigration(up{{.FuncName}}, down{{.FuncName}})
+}
+
+func up{{.FuncName}}(ctx context.Context, tx *sqlx.Tx) error {
+	return nil
+}
+
+func down{{.FuncName}}(ctx context.Context, tx *sqlx.Tx) error {
+	return nil
+}
+`))
+
+type GoCreator struct {
+	dir string
+}
+
+func New(dir string) *GoCreator {
+	return &GoCreator{
+		dir: dir,
+	}
+}
+
+func (g *GoCreator) Create(migrationName string) (*os.File, error) {
+	migrationsDir, err := utils.GetAbsoluteMigrationsDir(g.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := utils.GenerateRandomString(5)
+	f, err := utils.CreateMigrationFile(
+		time.Now(),
+		migrationsDir,
+		migrationName,
+		suffix,
+		"go",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	err = migrationTemplate.Execute(f, struct {
+		PackageName string
+		FuncName    string
+	}{
+		PackageName: getPackageName(migrationsDir),
+		FuncName:    toCamelCase(migrationName) + suffix,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("write migration template: %w", err)
+	}
+
+	return f, nil
+}
+
+// getPackageName returns Go package name for migrations dir.
+func getPackageName(migrationsDir string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return unicode.ToLower(r)
+		default:
+			return -1
+		}
+	}, filepath.Base(migrationsDir))
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return defaultPackageName
+	}
+
+	return name
+}
+
+// toCamelCase converts migration name like "create foo_table" to "CreateFooTable".
+func toCamelCase(migrationName string) string {
+	words := strings.FieldsFunc(migrationName, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		b.WriteString(strings.ToUpper(word[:1]))
+		b.WriteString(word[1:])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package creatorgo_test
+
+import (
+	"fmt"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	creatorgo "github.com/wursta/gomigrator/internal/creator/go"
+)
+
+func TestCreateSuccess(t *testing.T) {
+	tests := []struct {
+		name             string
+		expectedFuncName string
+	}{
+		{
+			name:             "backfill_foo",
+			expectedFuncName: "BackfillFoo",
+		},
+		{
+			name:             "backfill foo",
+			expectedFuncName: "BackfillFoo",
+		},
+		{
+			name:             "fill bar_table names",
+			expectedFuncName: "FillBarTableNames",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			testCase := tt
+			t.Parallel()
+
+			c := creatorgo.New("./test/migrations")
+			f, err := c.Create(testCase.name)
+			if err == nil {
+				defer os.Remove(f.Name())
+			}
+
+			require.Nil(t, err)
+
+			pattern := regexp.MustCompile(`^.*/\d{4}_\d{2}_\d{2}T\d{2}_\d{2}_\d{2}__` + testCase.name + `__(?P<SUFFIX>[a-zA-Z]{5}).go$`)
+			require.Regexp(t, pattern, f.Name())
+			suffix := pattern.FindStringSubmatch(f.Name())[1]
+
+			content, err := os.ReadFile(f.Name())
+			require.Nil(t, err)
+
+			parsedFile, err := goparser.ParseFile(token.NewFileSet(), f.Name(), content, 0)
+			require.Nil(t, err)
+			require.Equal(t, "migrations", parsedFile.Name.Name)
+
+			require.Contains(
+				t,
+				string(content),
+				"migratorApp.AddMigration(up"+testCase.expectedFuncName+suffix+", down"+testCase.expectedFuncName+suffix+")",
+			)
+		})
+	}
+}
+
+func TestCreateFailure(t *testing.T) {
+	tests := map[string]struct {
+		name          string
+		migrationsDir string
+		errPattern    *regexp.Regexp
+	}{
+		"non-existent dir": {
+			name:          "backfill_foo",
+			migrationsDir: "./test/non_existent_dir",
+			errPattern: regexp.MustCompile(
+				`create migration file: open .*\d{4}_\d{2}_\d{2}T\d{2}_\d{2}_\d{2}__backfill_foo__[a-zA-Z]{5}.go: .*`,
+			),
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", i), func(t *testing.T) {
+			testCase := tt
+
+			t.Parallel()
+
+			c := creatorgo.New(testCase.migrationsDir)
+			_, err := c.Create(testCase.name)
+
+			require.Regexp(t, testCase.errPattern, err)
+		})
+	}
+}
@@ -2,32 +2,86 @@ package pgmigrator
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib" // postgres driver
 	"github.com/jmoiron/sqlx"
-	"github.com/wursta/gomigrator/internal/migrator"
+	"github.com/wursta/gomigrator/pkg/migrator"
 )
 
+const (
+	DefaultHistorySchema = "public"
+	DefaultHistoryTable  = "dbmigrations"
+)
+
+var identifierRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 type PgMigrator struct {
 	dsn              string
 	migrationsSchema string
 	migratinsTable   string
 	mu               sync.Mutex
 	db               *sqlx.DB
+	// externalDB is set if db was passed with migrator.WithDB and must not be closed.
+	externalDB bool
+	logger     *slog.Logger
+	// lockConn is a dedicated connection holding run lock, lockPID is its backend pid.
+	lockConn *sqlx.Conn
+	lockPID  *int64
+	// toolVersion is recorded in migrations event log.
+	toolVersion string
+	// lockTimeout and noWait limit waiting for locks of migrations.
+	lockTimeout time.Duration
+	noWait      bool
 }
 
-func New(dsn string) *PgMigrator {
-	return &PgMigrator{
+// New returns PostgreSQL migrator keeping migrations log in historySchema.historyTable.
+// Empty historySchema and historyTable are replaced with DefaultHistorySchema and DefaultHistoryTable.
+func New(dsn, historySchema, historyTable string, opts ...migrator.Option) *PgMigrator {
+	options := migrator.NewOptions(opts...)
+
+	if historySchema == "" {
+		historySchema = DefaultHistorySchema
+	}
+	if historyTable == "" {
+		historyTable = DefaultHistoryTable
+	}
+
+	m := &PgMigrator{
 		dsn:              dsn,
-		migrationsSchema: "public",
-		migratinsTable:   "dbmigrations",
+		migrationsSchema: historySchema,
+		migratinsTable:   historyTable,
+		logger:           options.Logger,
+		toolVersion:      options.ToolVersion,
+		lockTimeout:      options.LockTimeout,
+		noWait:           options.NoWait,
+	}
+	if options.DB != nil {
+		m.db = sqlx.NewDb(options.DB, "pgx")
+		m.externalDB = true
 	}
+
+	return m
+}
+
+// historyTable returns qualified name of migrations log table.
+// Names are validated in Init, so they are used in queries without quoting.
+func (m *PgMigrator) historyTable() string {
+	return m.migrationsSchema + "." + m.migratinsTable
+}
+
+// eventsTable returns qualified name of migrations event log table.
+func (m *PgMigrator) eventsTable() string {
+	return m.historyTable() + "_events"
 }
 
 func (m *PgMigrator) Connect(ctx context.Context) error {
@@ -39,7 +93,7 @@ func (m *PgMigrator) Connect(ctx context.Context) error {
 		return nil
 	}
 
-	db, err := sqlx.ConnectContext(ctx, "pgx", m.dsn)
+	db, err := sqlx.ConnectContext(ctx, "pgx", connectionDSN(m.dsn))
 	if err != nil {
 		return err
 	}
@@ -54,8 +108,17 @@ func (m *PgMigrator) Connect(ctx context.Context) error {
 	return nil
 }
 
+// connectionDSN replaces cockroachdb:// scheme with postgres://, CockroachDB uses PostgreSQL protocol.
+func connectionDSN(dsn string) string {
+	if scheme, rest, found := strings.Cut(dsn, "://"); found && strings.EqualFold(scheme, "cockroachdb") {
+		return "postgres://" + rest
+	}
+
+	return dsn
+}
+
 func (m *PgMigrator) Close() error {
-	if m.db == nil {
+	if m.db == nil || m.externalDB {
 		return nil
 	}
 
@@ -71,35 +134,96 @@ func (m *PgMigrator) Close() error {
 }
 
 func (m *PgMigrator) Init(ctx context.Context) error {
+	for _, name := range []string{m.migrationsSchema, m.migratinsTable} {
+		if !identifierRe.MatchString(name) {
+			return fmt.Errorf("invalid migrations log table name %q: only latin letters, digits and underscores are allowed", name)
+		}
+	}
+
+	migrationsTableExists, err := m.Initialized(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !migrationsTableExists {
+		err = m.createMigrationsTable(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.upgradeMigrationsTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	return m.createEventsTable(ctx)
+}
+
+// Initialized reports whether migrations log table exists.
+func (m *PgMigrator) Initialized(ctx context.Context) (bool, error) {
 	row := m.db.QueryRowxContext(
 		ctx,
 		`SELECT EXISTS (
 			SELECT FROM information_schema.tables 
-			WHERE  table_schema = 'public'
-			AND    table_name   = 'dbmigrations'
+			WHERE  table_schema = $1
+			AND    table_name   = $2
 		) as exists`,
+		m.migrationsSchema,
+		m.migratinsTable,
 	)
 
 	var migrationsTableExists bool
 	err := row.Scan(&migrationsTableExists)
 	if err != nil {
-		return fmt.Errorf("error while scan existence row: %w", err)
+		return false, fmt.Errorf("error while scan existence row: %w", err)
 	}
 
-	if migrationsTableExists {
-		return nil
+	return migrationsTableExists, nil
+}
+
+// historyTableUpgrades adds columns missing in migrations log tables created by previous versions.
+// %s is replaced with qualified name of migrations log table.
+var historyTableUpgrades = []string{
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS owner_pid INTEGER`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS owner_host VARCHAR(255)`,
+	// started_at хранится с часовым поясом, чтобы сравнивать его с backend_start из pg_stat_activity.
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS duration_ms BIGINT`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS error_message TEXT`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS error_code VARCHAR(16)`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS error_statement INTEGER`,
+	`ALTER TABLE %s ADD COLUMN IF NOT EXISTS baselined BOOLEAN NOT NULL DEFAULT FALSE`,
+}
+
+func (m *PgMigrator) upgradeMigrationsTable(ctx context.Context) error {
+	for _, stmt := range historyTableUpgrades {
+		_, err := m.db.ExecContext(ctx, fmt.Sprintf(stmt, m.historyTable()))
+		if err != nil {
+			return fmt.Errorf("error while upgrade migrations log table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (m *PgMigrator) createMigrationsTable(ctx context.Context) error {
+	err := m.createMigrationsSchema(ctx)
+	if err != nil {
+		return err
 	}
 
 	_, err = m.db.ExecContext(
 		ctx,
-		`CREATE TABLE public.dbmigrations (
+		`CREATE TABLE `+m.historyTable()+` (
 			id SERIAL NOT NULL,
 			file_path VARCHAR(255) NOT NULL,
 			file_name VARCHAR(255) NOT NULL,
 			status VARCHAR(10) NOT NULL,
 			create_dt timestamp NOT NULL,
 			migrate_dt timestamp NOT NULL,
-			CONSTRAINT dbmigrations_pk PRIMARY KEY (id)
+			CONSTRAINT `+m.migratinsTable+`_pk PRIMARY KEY (id)
 		)`,
 	)
 
@@ -110,17 +234,13 @@ func (m *PgMigrator) Init(ctx context.Context) error {
 	if errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation {
 		return nil
 	}
-	if err != nil {
-		return err
-	}
-
 	if err != nil {
 		return fmt.Errorf("error while create migrations log table: %w", err)
 	}
 
 	_, err = m.db.ExecContext(
 		ctx,
-		`CREATE UNIQUE INDEX unq_dbmigrations_file_name ON public.dbmigrations (file_name)`,
+		`CREATE UNIQUE INDEX unq_`+m.migratinsTable+`_file_name ON `+m.historyTable()+` (file_name)`,
 	)
 	if err != nil {
 		return fmt.Errorf("error while create index on migrations log table: %w", err)
@@ -129,12 +249,72 @@ func (m *PgMigrator) Init(ctx context.Context) error {
 	return nil
 }
 
-func (m *PgMigrator) Up(ctx context.Context, migrations []migrator.Migration) error {
+// createEventsTable creates append-only migrations event log table if it doesn't exist.
+func (m *PgMigrator) createEventsTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS `+m.eventsTable()+` (
+			id BIGSERIAL PRIMARY KEY,
+			migration VARCHAR(255) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			from_status VARCHAR(10) NOT NULL,
+			to_status VARCHAR(10) NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			os_user VARCHAR(255) NOT NULL,
+			hostname VARCHAR(255) NOT NULL,
+			tool_version VARCHAR(64) NOT NULL,
+			error TEXT
+		)`,
+	)
+
+	// Параллельный CREATE TABLE IF NOT EXISTS может завершиться ошибкой уникальности в pg_type,
+	// значит таблица уже создана другим мигратором.
+	var e *pgconn.PgError
+	if errors.As(err, &e) && e.Code == pgerrcode.UniqueViolation {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error while create migrations event log table: %w", err)
+	}
+
+	return nil
+}
+
+// createMigrationsSchema creates schema of migrations log table if it doesn't exist.
+// Existence is checked first, because CREATE SCHEMA IF NOT EXISTS requires CREATE privilege
+// on database even for existing schemas like public.
+func (m *PgMigrator) createMigrationsSchema(ctx context.Context) error {
+	var schemaExists bool
+	err := m.db.QueryRowxContext(
+		ctx,
+		`SELECT EXISTS (SELECT FROM pg_namespace WHERE nspname = $1) as exists`,
+		m.migrationsSchema,
+	).Scan(&schemaExists)
+	if err != nil {
+		return fmt.Errorf("error while scan schema existence row: %w", err)
+	}
+	if schemaExists {
+		return nil
+	}
+
+	_, err = m.db.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+m.migrationsSchema)
+	if err != nil {
+		return fmt.Errorf("error while create migrations log schema: %w", err)
+	}
+
+	return nil
+}
+
+// Up applies migrations and returns migrations which were applied by this call.
+func (m *PgMigrator) Up(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	applied := make([]migrator.Migration, 0, len(migrations))
 	for i := range migrations {
-		log.Println("Start migration:", migrations[i].Name)
+		m.logger.InfoContext(ctx, "Start migration", "migration", migrations[i].Name)
 
-		if isMigrated(ctx, m.db, migrations[i].Name) {
-			log.Println("Skip migration:", migrations[i].Name)
+		if m.isMigrated(ctx, migrations[i].Name) {
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
 			continue
 		}
 
@@ -145,26 +325,31 @@ func (m *PgMigrator) Up(ctx context.Context, migrations []migrator.Migration) er
 			migrator.MigrationStatusMigrating,
 			migrator.MigrationStatusMigrated,
 			migrator.MigrationStatusFailed,
-			func(ctx context.Context, db *sqlx.DB, migration migrator.Migration) bool {
+			func(status migrator.MigrationStatus) bool {
 				// Если взяли lock, надо ещё раз проверить, что миграция ещё не выполнена.
-				return !isMigrated(ctx, db, migration.Name)
+				return !isMigratedStatus(status)
 			},
 		)
 		if err != nil {
-			return fmt.Errorf("error while up migration %s: %w", migrations[i].Name, err)
+			return applied, fmt.Errorf("error while up migration %s: %w", migrations[i].Name, err)
 		}
 		if migrated {
-			log.Println("Success migration:", migrations[i].Name)
+			m.logger.InfoContext(ctx, "Success migration", "migration", migrations[i].Name)
+			migration := migrations[i]
+			migration.Status = migrator.MigrationStatusMigrated
+			applied = append(applied, migration)
 		} else {
-			log.Println("Skip migration:", migrations[i].Name)
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
 		}
 	}
-	return nil
+	return applied, nil
 }
 
-func (m *PgMigrator) Down(ctx context.Context, migrations []migrator.Migration) error {
+// Down rollbacks migrations and returns migrations which were rolled back by this call.
+func (m *PgMigrator) Down(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	rolledBack := make([]migrator.Migration, 0, len(migrations))
 	for i := range migrations {
-		log.Println("Start rollback:", migrations[i].Name)
+		m.logger.InfoContext(ctx, "Start rollback", "migration", migrations[i].Name)
 
 		_, err := m.ApplyMigration(
 			ctx,
@@ -176,12 +361,15 @@ func (m *PgMigrator) Down(ctx context.Context, migrations []migrator.Migration)
 			nil,
 		)
 		if err != nil {
-			return fmt.Errorf("error while rollback migration %s: %w", migrations[i].Name, err)
+			return rolledBack, fmt.Errorf("error while rollback migration %s: %w", migrations[i].Name, err)
 		}
 
-		log.Println("Success rollback:", migrations[i].Name)
+		m.logger.InfoContext(ctx, "Success rollback", "migration", migrations[i].Name)
+		migration := migrations[i]
+		migration.Status = migrator.MigrationStatusNew
+		rolledBack = append(rolledBack, migration)
 	}
-	return nil
+	return rolledBack, nil
 }
 
 func (m *PgMigrator) ApplyMigration(
@@ -191,30 +379,74 @@ func (m *PgMigrator) ApplyMigration(
 	startStatus,
 	successStatus,
 	failStatus migrator.MigrationStatus,
-	afterLockCheck func(ctx context.Context, db *sqlx.DB, migration migrator.Migration) bool,
+	afterLockCheck func(status migrator.MigrationStatus) bool,
 ) (bool, error) {
-	err := lock(ctx, m.db, migration)
+	lock, err := m.lock(ctx, migration)
 	if err != nil {
 		return false, fmt.Errorf("error while locking migration row: %w", err)
 	}
-	defer unlock(ctx, m.db, migration.Name)
+	defer m.unlock(ctx, lock)
+
+	fromStatus, err := m.migrationStatus(ctx, migration.Name)
+	if err != nil {
+		return false, err
+	}
 
 	if afterLockCheck != nil {
-		if !afterLockCheck(ctx, m.db, migration) {
+		if !afterLockCheck(fromStatus) {
 			return false, nil
 		}
 	}
 
-	err = updateMigrationStatus(ctx, m.db, migration, startStatus)
+	err = m.startMigration(ctx, migration, startStatus)
 	if err != nil {
 		return false, err
 	}
 
-	tx, err := m.db.BeginTxx(ctx, nil)
+	started := time.Now()
+	if migration.NoTransaction {
+		err = runMigrationNoTx(ctx, m.db, migration, direction)
+		if err != nil {
+			// Без транзакции изменения могли примениться частично, поэтому миграция всегда помечается как failed.
+			event := migrator.NewEvent(migration, direction, fromStatus, migrator.MigrationStatusFailed, started, err)
+			updateStatusErr := m.finishMigration(ctx, migration, event, err)
+			if updateStatusErr != nil {
+				return false, updateStatusErr
+			}
+			return false, fmt.Errorf("migration without transaction may be partially applied: %w", err)
+		}
+	} else {
+		err = runMigrationTx(ctx, m.db, migration, direction)
+		if err != nil {
+			event := migrator.NewEvent(migration, direction, fromStatus, failStatus, started, err)
+			updateStatusErr := m.finishMigration(ctx, migration, event, err)
+			if updateStatusErr != nil {
+				return false, updateStatusErr
+			}
+			return false, err
+		}
+	}
+
+	event := migrator.NewEvent(migration, direction, fromStatus, successStatus, started, nil)
+	err = m.finishMigration(ctx, migration, event, nil)
 	if err != nil {
 		return false, err
 	}
 
+	return true, nil
+}
+
+func runMigrationTx(
+	ctx context.Context,
+	db *sqlx.DB,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
 	if direction == migrator.MigrationDirectionUp {
 		err = migration.UpHandlerContext(ctx, tx)
 	} else {
@@ -222,21 +454,64 @@ func (m *PgMigrator) ApplyMigration(
 	}
 	if err != nil {
 		tx.Rollback()
-		updateStatusErr := updateMigrationStatus(ctx, m.db, migration, failStatus)
-		if updateStatusErr != nil {
-			return false, updateStatusErr
-		}
-		return false, err
+		return err
 	}
 
-	tx.Commit()
+	return tx.Commit()
+}
 
-	err = updateMigrationStatus(ctx, m.db, migration, successStatus)
+// runMigrationNoTx runs migration outside of transaction on a dedicated connection.
+func runMigrationNoTx(
+	ctx context.Context,
+	db *sqlx.DB,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+) error {
+	conn, err := db.Connx(ctx)
 	if err != nil {
-		return false, err
+		return err
 	}
+	defer conn.Close()
 
-	return true, nil
+	if direction == migrator.MigrationDirectionUp {
+		return migration.UpHandlerNoTxContext(ctx, conn)
+	}
+
+	return migration.DownHandlerNoTxContext(ctx, conn)
+}
+
+// SetChecksum records checksum of migration file.
+func (m *PgMigrator) SetChecksum(ctx context.Context, migrationName, checksum string) error {
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`UPDATE `+m.historyTable()+` SET checksum = :checksum WHERE file_name = :migration_filename`,
+		map[string]interface{}{
+			"migration_filename": migrationName,
+			"checksum":           checksum,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error while set migration checksum: %w", err)
+	}
+
+	return nil
+}
+
+// selectMigrations returns query selecting migrations from migrations log.
+// Migration is orphaned if backend which set migrating status has gone, backend with reused pid
+// is started after the migration. Visibility of backend_start of other users is restricted,
+// so backends with unknown start are considered as owners.
+func (m *PgMigrator) selectMigrations() string {
+	return `SELECT id, file_path, file_name, status, create_dt, migrate_dt, COALESCE(checksum, '') AS checksum,
+			owner_pid, COALESCE(owner_host, '') AS owner_host, started_at,
+			duration_ms, COALESCE(error_message, '') AS error_message, COALESCE(error_code, '') AS error_code, error_statement,
+			baselined,
+			(status = '` + string(migrator.MigrationStatusMigrating) + `' AND owner_pid IS NOT NULL AND NOT EXISTS (
+				SELECT FROM pg_stat_activity a
+				WHERE a.pid = owner_pid
+				AND (a.backend_start IS NULL OR a.backend_start <= started_at)
+			)) AS orphaned
+		FROM ` + m.historyTable()
 }
 
 func (m *PgMigrator) GetLastMigations(
@@ -246,8 +521,7 @@ func (m *PgMigrator) GetLastMigations(
 ) ([]migrator.Migration, error) {
 	rows, err := m.db.NamedQueryContext(
 		ctx,
-		`SELECT id, file_path, file_name, status, create_dt, migrate_dt 
-		FROM public.dbmigrations 
+		m.selectMigrations()+`
 		WHERE status = :migration_status
 		ORDER BY id DESC 
 		LIMIT :count`,
@@ -276,8 +550,7 @@ func (m *PgMigrator) GetLastMigations(
 func (m *PgMigrator) GetMigations(ctx context.Context) ([]migrator.Migration, error) {
 	rows, err := m.db.NamedQueryContext(
 		ctx,
-		`SELECT id, file_path, file_name, status, create_dt, migrate_dt
-		FROM public.dbmigrations 
+		m.selectMigrations()+`
 		ORDER BY id DESC`,
 		map[string]interface{}{},
 	)
@@ -298,30 +571,157 @@ func (m *PgMigrator) GetMigations(ctx context.Context) ([]migrator.Migration, er
 	return migrations, nil
 }
 
-func isMigrated(ctx context.Context, db *sqlx.DB, migrationFileName string) bool {
-	rows, err := db.NamedQueryContext(
+func (m *PgMigrator) isMigrated(ctx context.Context, migrationFileName string) bool {
+	status, err := m.migrationStatus(ctx, migrationFileName)
+	if err != nil {
+		return false
+	}
+
+	return isMigratedStatus(status)
+}
+
+func isMigratedStatus(status migrator.MigrationStatus) bool {
+	return status == migrator.MigrationStatusMigrated || status == migrator.MigrationStatusMigrating
+}
+
+// migrationStatus returns status of migration, migrations missing in migrations log have new status.
+func (m *PgMigrator) migrationStatus(ctx context.Context, migrationFileName string) (migrator.MigrationStatus, error) {
+	rows, err := m.db.NamedQueryContext(
 		ctx,
-		"SELECT status FROM public.dbmigrations WHERE file_name = :migration_filename",
+		"SELECT status FROM "+m.historyTable()+" WHERE file_name = :migration_filename",
 		map[string]interface{}{
 			"migration_filename": migrationFileName,
 		},
 	)
 	if err != nil {
-		return false
+		return "", err
 	}
+	defer rows.Close()
 
-	for rows.Next() {
-		var status migrator.MigrationStatus
-		rows.Scan(&status)
-		return status == migrator.MigrationStatusMigrated || status == migrator.MigrationStatusMigrating
+	status := migrator.MigrationStatusNew
+	if rows.Next() {
+		err = rows.Scan(&status)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return status, rows.Err()
+}
+
+// SetStatus records status of migration under the lock of migration without applying it.
+func (m *PgMigrator) SetStatus(
+	ctx context.Context,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+	status migrator.MigrationStatus,
+) error {
+	lock, err := m.lock(ctx, migration)
+	if err != nil {
+		return fmt.Errorf("error while locking migration row: %w", err)
+	}
+	defer m.unlock(ctx, lock)
+
+	started := time.Now()
+	fromStatus, err := m.migrationStatus(ctx, migration.Name)
+	if err != nil {
+		return fmt.Errorf("error while set migration status: %w", err)
+	}
+
+	err = m.updateMigrationStatus(ctx, migration, status)
+	if err != nil {
+		return fmt.Errorf("error while set migration status: %w", err)
+	}
+
+	return m.recordEvent(ctx, migrator.NewEvent(migration, direction, fromStatus, status, started, nil))
+}
+
+// Baseline records migrations as migrated without applying them, each under the lock of migration.
+func (m *PgMigrator) Baseline(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	baselined := make([]migrator.Migration, 0, len(migrations))
+	for i := range migrations {
+		ok, err := m.baselineMigration(ctx, migrations[i])
+		if err != nil {
+			return baselined, fmt.Errorf("error while baseline migration %s: %w", migrations[i].Name, err)
+		}
+		if !ok {
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
+			continue
+		}
+
+		migrations[i].Status = migrator.MigrationStatusMigrated
+		migrations[i].Baselined = true
+		baselined = append(baselined, migrations[i])
 	}
-	return false
+
+	return baselined, nil
 }
 
-func lock(ctx context.Context, db *sqlx.DB, migration migrator.Migration) error {
-	_, err := db.NamedExecContext(
+// baselineMigration records migration as migrated and baselined if it isn't migrated yet.
+func (m *PgMigrator) baselineMigration(ctx context.Context, migration migrator.Migration) (bool, error) {
+	lock, err := m.lock(ctx, migration)
+	if err != nil {
+		return false, fmt.Errorf("error while locking migration row: %w", err)
+	}
+	defer m.unlock(ctx, lock)
+
+	started := time.Now()
+	fromStatus, err := m.migrationStatus(ctx, migration.Name)
+	if err != nil {
+		return false, err
+	}
+	if isMigratedStatus(fromStatus) {
+		return false, nil
+	}
+
+	_, err = m.db.NamedExecContext(
 		ctx,
-		`INSERT INTO public.dbmigrations (file_path, file_name, status, create_dt, migrate_dt) 
+		`UPDATE `+m.historyTable()+`
+		SET
+			status = :migration_status,
+			migrate_dt = NOW(),
+			checksum = COALESCE(NULLIF(:checksum, ''), checksum),
+			duration_ms = NULL,
+			error_message = NULL,
+			error_code = NULL,
+			error_statement = NULL,
+			baselined = TRUE
+		WHERE file_name = :migration_filename`,
+		map[string]interface{}{
+			"migration_filename": migration.Name,
+			"migration_status":   migrator.MigrationStatusMigrated,
+			"checksum":           migration.Checksum,
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	event := migrator.NewEvent(
+		migration,
+		migrator.MigrationDirectionBaseline,
+		fromStatus,
+		migrator.MigrationStatusMigrated,
+		started,
+		nil,
+	)
+	return true, m.recordEvent(ctx, event)
+}
+
+// migrationLock is a lock of migration held on a dedicated connection.
+type migrationLock struct {
+	conn *sqlx.Conn
+	// id of migration is the second key of advisory lock.
+	id int64
+}
+
+// lock inserts row of migration if it doesn't exist and takes advisory lock of the row on a dedicated
+// connection, so unlock releases it on the same session. The lock is awaited within lockTimeout,
+// or isn't awaited if noWait is set.
+func (m *PgMigrator) lock(ctx context.Context, migration migrator.Migration) (*migrationLock, error) {
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`INSERT INTO `+m.historyTable()+` (file_path, file_name, status, create_dt, migrate_dt) 
 		 VALUES (:migration_filepath, :migration_filename, :migration_status, NOW(), NOW())`,
 		map[string]interface{}{
 			"migration_filepath": migration.FilePath,
@@ -334,48 +734,229 @@ func lock(ctx context.Context, db *sqlx.DB, migration migrator.Migration) error
 	// Значит запись в таблице уже есть.
 	var e *pgconn.PgError
 	if errors.As(err, &e) && e.Code != pgerrcode.UniqueViolation {
+		return nil, err
+	}
+
+	lock := &migrationLock{}
+	err = m.db.QueryRowxContext(
+		ctx,
+		`SELECT id FROM `+m.historyTable()+` WHERE file_name = $1`,
+		migration.Name,
+	).Scan(&lock.id)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.conn, err = m.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx := ctx
+	if m.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+	}
+
+	// Ключ блокировки включает имя таблицы, чтобы истории миграций разных сервисов в одной БД
+	// не блокировали друг друга.
+	err = migrator.PollLock(
+		lockCtx,
+		!m.noWait,
+		func(ctx context.Context) (bool, error) {
+			var locked bool
+			err := lock.conn.QueryRowxContext(
+				ctx,
+				"SELECT pg_try_advisory_lock(hashtext($1), $2)",
+				m.historyTable(),
+				lock.id,
+			).Scan(&locked)
+			return locked, err
+		},
+		func(ctx context.Context) string {
+			return m.advisoryLockHolder(ctx, lock.id)
+		},
+	)
+	if err != nil {
+		lock.conn.Close()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// unlock releases lock of migration and returns its connection to the pool.
+func (m *PgMigrator) unlock(ctx context.Context, lock *migrationLock) {
+	defer lock.conn.Close()
+
+	// Блокировка снимается и после отмены контекста, иначе соединение вернулось бы в пул с блокировкой.
+	_, err := lock.conn.ExecContext(
+		context.WithoutCancel(ctx),
+		"SELECT pg_advisory_unlock(hashtext($1), $2)",
+		m.historyTable(),
+		lock.id,
+	)
+	if err != nil {
+		m.logger.WarnContext(ctx, "Failed to release lock of migration", "error", err)
+		// Соединение закрывается вместо возврата в пул, вместе с сессией снимается и блокировка.
+		lock.conn.Raw(func(any) error {
+			return driver.ErrBadConn
+		})
+	}
+}
+
+// Lock takes run lock on a dedicated connection. Run lock is an advisory lock with keys
+// (hashtext(<history table>), 0), zero never matches ids of migrations locked by ApplyMigration.
+// Application name of the connection identifies the holder for other migrators waiting for the lock.
+func (m *PgMigrator) Lock(ctx context.Context) error {
+	return m.runLock(ctx, true)
+}
+
+// TryLock takes run lock if it is free.
+func (m *PgMigrator) TryLock(ctx context.Context) error {
+	return m.runLock(ctx, false)
+}
+
+func (m *PgMigrator) runLock(ctx context.Context, wait bool) error {
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
 		return err
 	}
 
-	_, err = db.NamedExecContext(
+	var applicationName string
+	var pid int64
+	err = conn.QueryRowxContext(
 		ctx,
-		`SELECT pg_advisory_lock(id) FROM public.dbmigrations WHERE file_name = :migration_filename`,
-		map[string]interface{}{
-			"migration_filename": migration.Name,
+		"SELECT set_config('application_name', $1, false), pg_backend_pid()",
+		migrator.CurrentOwner().ApplicationName(),
+	).Scan(&applicationName, &pid)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error while set application name: %w", err)
+	}
+
+	err = migrator.PollLock(
+		ctx,
+		wait,
+		func(ctx context.Context) (bool, error) {
+			var locked bool
+			err := conn.QueryRowxContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1), 0)", m.historyTable()).Scan(&locked)
+			return locked, err
 		},
+		m.lockHolder,
 	)
 	if err != nil {
+		conn.Close()
 		return err
 	}
+
+	m.lockConn = conn
+	m.lockPID = &pid
 	return nil
 }
 
-func unlock(ctx context.Context, db *sqlx.DB, migrationName string) {
-	db.NamedExecContext(
+// lockHolder returns description of the session holding run lock.
+func (m *PgMigrator) lockHolder(ctx context.Context) string {
+	return m.advisoryLockHolder(ctx, 0)
+}
+
+// advisoryLockHolder returns description of the session holding advisory lock with keys
+// (hashtext(<history table>), key), zero key is run lock and ids of migrations are locks of migrations.
+func (m *PgMigrator) advisoryLockHolder(ctx context.Context, key int64) string {
+	var pid int
+	var applicationName, clientAddr string
+	err := m.db.QueryRowxContext(
 		ctx,
-		`SELECT pg_advisory_unlock(id) FROM public.dbmigrations WHERE file_name = :migration_filename`,
+		`SELECT a.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), '')
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory'
+		AND l.granted
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND l.classid::bigint = (hashtext($1)::bigint & 4294967295)
+		AND l.objid = $2
+		AND l.objsubid = 2`,
+		m.historyTable(),
+		key,
+	).Scan(&pid, &applicationName, &clientAddr)
+	if err != nil {
+		return ""
+	}
+
+	if owner, ok := migrator.ParseApplicationName(applicationName); ok {
+		return owner.String()
+	}
+
+	return fmt.Sprintf("backend pid %d from %s", pid, clientAddr)
+}
+
+// Unlock releases run lock and returns its connection to the pool.
+func (m *PgMigrator) Unlock(ctx context.Context) error {
+	if m.lockConn == nil {
+		return nil
+	}
+
+	conn := m.lockConn
+	m.lockConn = nil
+	m.lockPID = nil
+	defer conn.Close()
+
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1), 0)", m.historyTable())
+	if err != nil {
+		return fmt.Errorf("error while release run lock: %w", err)
+	}
+
+	_, err = conn.ExecContext(ctx, "RESET application_name")
+	return err
+}
+
+func (m *PgMigrator) updateMigrationStatus(
+	ctx context.Context,
+	migration migrator.Migration,
+	migrationStatus migrator.MigrationStatus,
+) error {
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`UPDATE `+m.historyTable()+` 
+		SET
+			status = :migration_status,
+		 	migrate_dt = NOW(),
+			baselined = FALSE
+		WHERE file_name = :migration_filename`,
 		map[string]interface{}{
-			"migration_filename": migrationName,
+			"migration_filename": migration.Name,
+			"migration_status":   migrationStatus,
 		},
 	)
+	if err != nil {
+		return err
+	}
+	return nil
 }
 
-func updateMigrationStatus(
+// startMigration sets start status of migration and records its owner. Owner is the backend holding
+// run lock, it lives until migrations are applied, or the backend of the update if run lock isn't taken.
+func (m *PgMigrator) startMigration(
 	ctx context.Context,
-	db *sqlx.DB,
 	migration migrator.Migration,
 	migrationStatus migrator.MigrationStatus,
 ) error {
-	_, err := db.NamedExecContext(
+	_, err := m.db.NamedExecContext(
 		ctx,
-		`UPDATE public.dbmigrations 
+		`UPDATE `+m.historyTable()+`
 		SET
 			status = :migration_status,
-		 	migrate_dt = NOW()
+			migrate_dt = NOW(),
+			owner_pid = COALESCE(:owner_pid, pg_backend_pid()),
+			owner_host = :owner_host,
+			started_at = NOW()
 		WHERE file_name = :migration_filename`,
 		map[string]interface{}{
 			"migration_filename": migration.Name,
 			"migration_status":   migrationStatus,
+			"owner_pid":          m.lockPID,
+			"owner_host":         migrator.CurrentOwner().Host,
 		},
 	)
 	if err != nil {
@@ -383,3 +964,121 @@ func updateMigrationStatus(
 	}
 	return nil
 }
+
+// finishMigration sets final status of migration, records duration and error of applying
+// and appends event to migrations event log.
+func (m *PgMigrator) finishMigration(
+	ctx context.Context,
+	migration migrator.Migration,
+	event migrator.Event,
+	migrationErr error,
+) error {
+	params := map[string]interface{}{
+		"migration_filename": migration.Name,
+		"migration_status":   event.ToStatus,
+		"duration_ms":        event.DurationMS,
+		"error_message":      nil,
+		"error_code":         nil,
+		"error_statement":    migrator.StatementIndex(migrationErr),
+		"checksum":           migrator.AppliedChecksum(migration, event),
+	}
+	if migrationErr != nil {
+		params["error_message"] = migrationErr.Error()
+		params["error_code"] = errorCode(migrationErr)
+	}
+
+	// Контрольная сумма записывается тем же запросом, что и статус, чтобы применённая миграция не осталась без неё.
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`UPDATE `+m.historyTable()+`
+		SET
+			status = :migration_status,
+			migrate_dt = NOW(),
+			checksum = COALESCE(NULLIF(:checksum, ''), checksum),
+			duration_ms = :duration_ms,
+			error_message = :error_message,
+			error_code = :error_code,
+			error_statement = :error_statement,
+			baselined = FALSE
+		WHERE file_name = :migration_filename`,
+		params,
+	)
+	if err != nil {
+		return err
+	}
+
+	return m.recordEvent(ctx, event)
+}
+
+// recordEvent appends event to migrations event log.
+func (m *PgMigrator) recordEvent(ctx context.Context, event migrator.Event) error {
+	event.ToolVersion = m.toolVersion
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`INSERT INTO `+m.eventsTable()+` (
+			migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, error
+		)
+		VALUES (
+			:migration, :direction, :from_status, :to_status, :started_at, :finished_at, :duration_ms,
+			:os_user, :hostname, :tool_version, NULLIF(:error, '')
+		)`,
+		event,
+	)
+	if err != nil {
+		return fmt.Errorf("error while record migration event: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents returns events of migrations event log selected by filter in order they were recorded.
+func (m *PgMigrator) GetEvents(ctx context.Context, filter migrator.EventFilter) ([]migrator.Event, error) {
+	conditions := []string{}
+	params := map[string]interface{}{}
+	if filter.Migration != "" {
+		conditions = append(conditions, "migration = :migration")
+		params["migration"] = filter.Migration
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "started_at >= :since")
+		params["since"] = filter.Since
+	}
+
+	query := `SELECT id, migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, COALESCE(error, '') AS error
+		FROM ` + m.eventsTable()
+	if len(conditions) != 0 {
+		query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := m.db.NamedQueryContext(ctx, query+`
+		ORDER BY id`, params)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []migrator.Event{}
+	for rows.Next() {
+		var event migrator.Event
+		err = rows.StructScan(&event)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// errorCode returns SQLSTATE code of PostgreSQL error, or nil if err isn't returned by PostgreSQL.
+func errorCode(err error) interface{} {
+	var e *pgconn.PgError
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	return e.Code
+}
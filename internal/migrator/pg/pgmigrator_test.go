@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
-	"github.com/wursta/gomigrator/internal/migrator"
+	"github.com/wursta/gomigrator/pkg/migrator"
 )
 
 func TestUpSuccess(t *testing.T) {
@@ -19,7 +21,7 @@ func TestUpSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pgMigrator := New("testdsn")
+	pgMigrator := New("testdsn", "", "")
 	pgMigrator.db = db
 
 	ctx := context.Background()
@@ -45,16 +47,30 @@ func TestUpSuccess(t *testing.T) {
 
 		expectStatusCheckError(mock, migrationFileName)
 		expectLock(mock, migrationFilePath, migrationFileName)
-		expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrating)
+		expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
+		expectStartMigration(mock, migrationFileName)
 		mock.ExpectBegin()
 		mock.ExpectExec(fmt.Sprint("Test Query ", i)).WillReturnResult(sqlmock.NewResult(0, 0))
 		mock.ExpectCommit()
-		expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
-		expectUnlock(mock, migrationFileName)
+		expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusMigrated, nil, nil)
+		expectRecordEvent(
+			mock,
+			migrationFileName,
+			migrator.MigrationDirectionUp,
+			migrator.MigrationStatusNew,
+			migrator.MigrationStatusMigrated,
+			"",
+		)
+		expectUnlock(mock)
 	}
 
-	err = pgMigrator.Up(ctx, migrations)
+	applied, err := pgMigrator.Up(ctx, migrations)
 	require.Nil(t, err)
+	require.Len(t, applied, 3)
+	for i := range applied {
+		require.Equal(t, migrations[i].Name, applied[i].Name)
+		require.Equal(t, migrator.MigrationStatusMigrated, applied[i].Status)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Error(err)
@@ -67,7 +83,7 @@ func TestUpFailure(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pgMigrator := New("testdsn")
+	pgMigrator := New("testdsn", "", "")
 	pgMigrator.db = db
 
 	ctx := context.Background()
@@ -92,27 +108,57 @@ func TestUpFailure(t *testing.T) {
 
 	expectStatusCheckError(mock, migrationFileName)
 	expectLock(mock, migrationFilePath, migrationFileName)
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrating)
+	expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
+	expectStartMigration(mock, migrationFileName)
 	mock.ExpectBegin()
 	mock.ExpectExec("Test Query 0").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectCommit()
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
-	expectUnlock(mock, migrationFileName)
+	expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusMigrated, nil, nil)
+	expectRecordEvent(
+		mock,
+		migrationFileName,
+		migrator.MigrationDirectionUp,
+		migrator.MigrationStatusNew,
+		migrator.MigrationStatusMigrated,
+		"",
+	)
+	expectUnlock(mock)
 
 	migrationFileName = "migration_1.sql"
 	migrationFilePath = "./migrations/migration_1.sql"
 
 	expectStatusCheckError(mock, migrationFileName)
 	expectLock(mock, migrationFilePath, migrationFileName)
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrating)
+	expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
+	expectStartMigration(mock, migrationFileName)
 	mock.ExpectBegin()
-	mock.ExpectExec("Test Query 1").WillReturnError(errors.New("some DB error"))
+	mock.ExpectExec("Test Query 1").WillReturnError(&pgconn.PgError{
+		Severity: "ERROR",
+		Code:     pgerrcode.DuplicateTable,
+		Message:  "relation already exists",
+	})
 	mock.ExpectRollback()
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusFailed)
-	expectUnlock(mock, migrationFileName)
-
-	err = pgMigrator.Up(ctx, migrations)
+	expectFinishMigration(
+		mock,
+		migrationFileName,
+		migrator.MigrationStatusFailed,
+		"ERROR: relation already exists (SQLSTATE 42P07)",
+		pgerrcode.DuplicateTable,
+	)
+	expectRecordEvent(
+		mock,
+		migrationFileName,
+		migrator.MigrationDirectionUp,
+		migrator.MigrationStatusNew,
+		migrator.MigrationStatusFailed,
+		"ERROR: relation already exists (SQLSTATE 42P07)",
+	)
+	expectUnlock(mock)
+
+	applied, err := pgMigrator.Up(ctx, migrations)
 	require.NotNil(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, "migration_0.sql", applied[0].Name)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Error(err)
@@ -125,7 +171,7 @@ func TestDownSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pgMigrator := New("testdsn")
+	pgMigrator := New("testdsn", "", "")
 	pgMigrator.db = db
 
 	migrationFileName := "migration.sql"
@@ -146,16 +192,27 @@ func TestDownSuccess(t *testing.T) {
 	}
 
 	expectLock(mock, migrationFilePath, migrationFileName)
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrating)
+	expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
+	expectStartMigration(mock, migrationFileName)
 	mock.ExpectBegin()
 	mock.ExpectExec("Test Query").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectCommit()
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
-	expectUnlock(mock, migrationFileName)
+	expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusNew, nil, nil)
+	expectRecordEvent(
+		mock,
+		migrationFileName,
+		migrator.MigrationDirectionDown,
+		migrator.MigrationStatusMigrated,
+		migrator.MigrationStatusNew,
+		"",
+	)
+	expectUnlock(mock)
 
 	ctx := context.Background()
-	err = pgMigrator.Down(ctx, migrations)
+	rolledBack, err := pgMigrator.Down(ctx, migrations)
 	require.Nil(t, err)
+	require.Len(t, rolledBack, 1)
+	require.Equal(t, migrator.MigrationStatusNew, rolledBack[0].Status)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Error(err)
@@ -168,7 +225,7 @@ func TestDownFailure(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pgMigrator := New("testdsn")
+	pgMigrator := New("testdsn", "", "")
 	pgMigrator.db = db
 
 	migrationFileName := "migration.sql"
@@ -189,16 +246,26 @@ func TestDownFailure(t *testing.T) {
 	}
 
 	expectLock(mock, migrationFilePath, migrationFileName)
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrating)
+	expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
+	expectStartMigration(mock, migrationFileName)
 	mock.ExpectBegin()
 	mock.ExpectExec("Test Query").WillReturnError(errors.New("some DB error"))
 	mock.ExpectRollback()
-	expectUpdateMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
-	expectUnlock(mock, migrationFileName)
+	expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusMigrated, "some DB error", nil)
+	expectRecordEvent(
+		mock,
+		migrationFileName,
+		migrator.MigrationDirectionDown,
+		migrator.MigrationStatusMigrated,
+		migrator.MigrationStatusMigrated,
+		"some DB error",
+	)
+	expectUnlock(mock)
 
 	ctx := context.Background()
-	err = pgMigrator.Down(ctx, migrations)
+	rolledBack, err := pgMigrator.Down(ctx, migrations)
 	require.NotNil(t, err)
+	require.Empty(t, rolledBack)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Error(err)
@@ -211,14 +278,23 @@ func TestGetLastMigations(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	pgMigrator := New("testdsn")
+	pgMigrator := New("testdsn", "", "")
 	pgMigrator.db = db
 
 	createdDT := time.Now()
 	migratedDT := time.Now()
+	startedDT := time.Now()
 	mock.
-		ExpectQuery(`SELECT id, file_path, file_name, status, create_dt, migrate_dt 
-		FROM public.dbmigrations 
+		ExpectQuery(`SELECT id, file_path, file_name, status, create_dt, migrate_dt, COALESCE(checksum, '') AS checksum,
+			owner_pid, COALESCE(owner_host, '') AS owner_host, started_at,
+			duration_ms, COALESCE(error_message, '') AS error_message, COALESCE(error_code, '') AS error_code, error_statement,
+			baselined,
+			(status = 'migrating' AND owner_pid IS NOT NULL AND NOT EXISTS (
+				SELECT FROM pg_stat_activity a
+				WHERE a.pid = owner_pid
+				AND (a.backend_start IS NULL OR a.backend_start <= started_at)
+			)) AS orphaned
+		FROM public.dbmigrations
 		WHERE status = ?
 		ORDER BY id DESC 
 		LIMIT ?`).
@@ -232,6 +308,15 @@ func TestGetLastMigations(t *testing.T) {
 					sqlmock.NewColumn("status").OfType("VARCHAR", "").Nullable(false).WithLength(255),
 					sqlmock.NewColumn("create_dt").OfType("TIMESTAMP", time.Now()),
 					sqlmock.NewColumn("migrate_dt").OfType("TIMESTAMP", time.Now()),
+					sqlmock.NewColumn("checksum").OfType("VARCHAR", "").Nullable(false).WithLength(64),
+					sqlmock.NewColumn("owner_pid").OfType("INTEGER", 0).Nullable(true),
+					sqlmock.NewColumn("owner_host").OfType("VARCHAR", "").Nullable(false).WithLength(255),
+					sqlmock.NewColumn("started_at").OfType("TIMESTAMPTZ", time.Now()).Nullable(true),
+					sqlmock.NewColumn("duration_ms").OfType("BIGINT", 0).Nullable(true),
+					sqlmock.NewColumn("error_message").OfType("TEXT", "").Nullable(false),
+					sqlmock.NewColumn("error_code").OfType("VARCHAR", "").Nullable(false).WithLength(16),
+					sqlmock.NewColumn("error_statement").OfType("INTEGER", 0).Nullable(true),
+					sqlmock.NewColumn("orphaned").OfType("BOOL", false).Nullable(false),
 				).
 				AddRow(
 					1,
@@ -240,6 +325,15 @@ func TestGetLastMigations(t *testing.T) {
 					migrator.MigrationStatusMigrated,
 					createdDT,
 					migratedDT,
+					"checksum",
+					42,
+					"pod-1",
+					startedDT,
+					1500,
+					"",
+					"",
+					nil,
+					false,
 				),
 		)
 
@@ -253,6 +347,474 @@ func TestGetLastMigations(t *testing.T) {
 	require.Equal(t, migrator.MigrationStatus("migrated"), migrations[0].Status)
 	require.Equal(t, createdDT, migrations[0].CreateDT)
 	require.Equal(t, &migratedDT, migrations[0].MigrateDT)
+	require.Equal(t, "checksum", migrations[0].Checksum)
+	require.Equal(t, int64(42), *migrations[0].OwnerPID)
+	require.Equal(t, "pod-1", migrations[0].OwnerHost)
+	require.Equal(t, &startedDT, migrations[0].StartedAt)
+	require.False(t, migrations[0].Orphaned)
+	require.Equal(t, int64(1500), *migrations[0].DurationMS)
+	require.Empty(t, migrations[0].ErrorMessage)
+	require.Nil(t, migrations[0].ErrorStatement)
+}
+
+func TestUpRecordsChecksum(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+
+	migrationFileName := "migration.sql"
+	migrationFilePath := "./migrations/migration.sql"
+	migrations := []migrator.Migration{
+		{
+			FilePath: migrationFilePath,
+			Name:     migrationFileName,
+			Checksum: "checksum",
+			UpHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, "Test Query")
+				return err
+			},
+		},
+	}
+
+	expectStatusCheckError(mock, migrationFileName)
+	expectLock(mock, migrationFilePath, migrationFileName)
+	expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
+	expectStartMigration(mock, migrationFileName)
+	mock.ExpectBegin()
+	mock.ExpectExec("Test Query").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	expectFinishMigrationWithChecksum(mock, migrationFileName, migrator.MigrationStatusMigrated, "checksum", nil, nil)
+	expectRecordEvent(
+		mock,
+		migrationFileName,
+		migrator.MigrationDirectionUp,
+		migrator.MigrationStatusNew,
+		migrator.MigrationStatusMigrated,
+		"",
+	)
+	expectUnlock(mock)
+
+	ctx := context.Background()
+	_, err = pgMigrator.Up(ctx, migrations)
+	require.Nil(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNoTransactionMigration(t *testing.T) {
+	migrationFileName := "migration.sql"
+	migrationFilePath := "./migrations/migration.sql"
+	migration := migrator.Migration{
+		FilePath:      migrationFilePath,
+		Name:          migrationFileName,
+		NoTransaction: true,
+		UpHandlerNoTxContext: func(ctx context.Context, conn *sqlx.Conn) error {
+			_, err := conn.ExecContext(ctx, "CREATE INDEX CONCURRENTLY foo_idx ON foo (id)")
+			return err
+		},
+		DownHandlerNoTxContext: func(ctx context.Context, conn *sqlx.Conn) error {
+			_, err := conn.ExecContext(ctx, "DROP INDEX CONCURRENTLY foo_idx")
+			return err
+		},
+	}
+
+	t.Run("case up success", func(t *testing.T) {
+		db, mock, err := getDBMock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pgMigrator := New("testdsn", "", "")
+		pgMigrator.db = db
+
+		expectStatusCheckError(mock, migrationFileName)
+		expectLock(mock, migrationFilePath, migrationFileName)
+		expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusNew)
+		expectStartMigration(mock, migrationFileName)
+		mock.ExpectExec("CREATE INDEX CONCURRENTLY foo_idx ON foo (id)").WillReturnResult(sqlmock.NewResult(0, 0))
+		expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusMigrated, nil, nil)
+		expectRecordEvent(
+			mock,
+			migrationFileName,
+			migrator.MigrationDirectionUp,
+			migrator.MigrationStatusNew,
+			migrator.MigrationStatusMigrated,
+			"",
+		)
+		expectUnlock(mock)
+
+		applied, err := pgMigrator.Up(context.Background(), []migrator.Migration{migration})
+		require.Nil(t, err)
+		require.Len(t, applied, 1)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("case down failure", func(t *testing.T) {
+		db, mock, err := getDBMock()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pgMigrator := New("testdsn", "", "")
+		pgMigrator.db = db
+
+		expectLock(mock, migrationFilePath, migrationFileName)
+		expectMigrationStatus(mock, migrationFileName, migrator.MigrationStatusMigrated)
+		expectStartMigration(mock, migrationFileName)
+		mock.ExpectExec("DROP INDEX CONCURRENTLY foo_idx").WillReturnError(errors.New("some DB error"))
+		// Откат без транзакции мог примениться частично, поэтому статус failed, а не migrated.
+		expectFinishMigration(mock, migrationFileName, migrator.MigrationStatusFailed, "some DB error", nil)
+		expectRecordEvent(
+			mock,
+			migrationFileName,
+			migrator.MigrationDirectionDown,
+			migrator.MigrationStatusMigrated,
+			migrator.MigrationStatusFailed,
+			"some DB error",
+		)
+		expectUnlock(mock)
+
+		rolledBack, err := pgMigrator.Down(context.Background(), []migrator.Migration{migration})
+		require.ErrorContains(t, err, "may be partially applied: some DB error")
+		require.Empty(t, rolledBack)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestInitCustomHistoryTable(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "billing", "migrations")
+	pgMigrator.db = db
+
+	mock.
+		ExpectQuery(`SELECT EXISTS (
+			SELECT FROM information_schema.tables 
+			WHERE  table_schema = $1
+			AND    table_name   = $2
+		) as exists`).
+		WithArgs("billing", "migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.
+		ExpectQuery("SELECT EXISTS (SELECT FROM pg_namespace WHERE nspname = $1) as exists").
+		WithArgs("billing").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("CREATE SCHEMA IF NOT EXISTS billing").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE billing.migrations (
+			id SERIAL NOT NULL,
+			file_path VARCHAR(255) NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			status VARCHAR(10) NOT NULL,
+			create_dt timestamp NOT NULL,
+			migrate_dt timestamp NOT NULL,
+			CONSTRAINT migrations_pk PRIMARY KEY (id)
+		)`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.
+		ExpectExec("CREATE UNIQUE INDEX unq_migrations_file_name ON billing.migrations (file_name)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, stmt := range historyTableUpgrades {
+		mock.ExpectExec(fmt.Sprintf(stmt, "billing.migrations")).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS billing.migrations_events (
+			id BIGSERIAL PRIMARY KEY,
+			migration VARCHAR(255) NOT NULL,
+			direction VARCHAR(10) NOT NULL,
+			from_status VARCHAR(10) NOT NULL,
+			to_status VARCHAR(10) NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			finished_at TIMESTAMPTZ NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			os_user VARCHAR(255) NOT NULL,
+			hostname VARCHAR(255) NOT NULL,
+			tool_version VARCHAR(64) NOT NULL,
+			error TEXT
+		)`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.Nil(t, pgMigrator.Init(context.Background()))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInitInvalidHistoryTable(t *testing.T) {
+	db, _, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, names := range [][2]string{{"public", "db-migrations"}, {"public; DROP TABLE foo", "dbmigrations"}} {
+		pgMigrator := New("testdsn", names[0], names[1])
+		pgMigrator.db = db
+
+		require.ErrorContains(t, pgMigrator.Init(context.Background()), "invalid migrations log table name")
+	}
+}
+
+func TestConnectionDSN(t *testing.T) {
+	require.Equal(t, "postgres://root@dbhost:26257/dbname", connectionDSN("cockroachdb://root@dbhost:26257/dbname"))
+	require.Equal(t, "postgresql://user@dbhost/dbname", connectionDSN("postgresql://user@dbhost/dbname"))
+	require.Equal(t, "host=dbhost dbname=dbname", connectionDSN("host=dbhost dbname=dbname"))
+}
+
+func TestSetStatus(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+
+	migration := migrator.Migration{FilePath: "./migrations/migration_1.sql", Name: "migration_1.sql"}
+	expectLock(mock, migration.FilePath, migration.Name)
+	expectMigrationStatus(mock, migration.Name, migrator.MigrationStatusMigrating)
+	expectUpdateMigrationStatus(mock, migration.Name, migrator.MigrationStatusFailed)
+	expectRecordEvent(mock, migration.Name, migrator.MigrationDirectionFix, migrator.MigrationStatusMigrating, migrator.MigrationStatusFailed, "")
+	expectUnlock(mock)
+
+	err = pgMigrator.SetStatus(context.Background(), migration, migrator.MigrationDirectionFix, migrator.MigrationStatusFailed)
+	require.Nil(t, err)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+
+	migrations := []migrator.Migration{
+		{FilePath: "./migrations/migration_1.sql", Name: "migration_1.sql", Checksum: "checksum"},
+		{FilePath: "./migrations/migration_2.sql", Name: "migration_2.sql"},
+	}
+	expectLock(mock, migrations[0].FilePath, migrations[0].Name)
+	expectMigrationStatus(mock, migrations[0].Name, migrator.MigrationStatusNew)
+	mock.
+		ExpectExec(`UPDATE public.dbmigrations
+		SET
+			status = ?,
+			migrate_dt = NOW(),
+			checksum = COALESCE(NULLIF(?, ''), checksum),
+			duration_ms = NULL,
+			error_message = NULL,
+			error_code = NULL,
+			error_statement = NULL,
+			baselined = TRUE
+		WHERE file_name = ?`).
+		WithArgs(migrator.MigrationStatusMigrated, "checksum", migrations[0].Name).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordEvent(
+		mock,
+		migrations[0].Name,
+		migrator.MigrationDirectionBaseline,
+		migrator.MigrationStatusNew,
+		migrator.MigrationStatusMigrated,
+		"",
+	)
+	expectUnlock(mock)
+	// Уже применённая миграция пропускается.
+	expectLock(mock, migrations[1].FilePath, migrations[1].Name)
+	expectMigrationStatus(mock, migrations[1].Name, migrator.MigrationStatusMigrated)
+	expectUnlock(mock)
+
+	baselined, err := pgMigrator.Baseline(context.Background(), migrations)
+	require.Nil(t, err)
+	require.Len(t, baselined, 1)
+	require.Equal(t, migrations[0].Name, baselined[0].Name)
+	require.Equal(t, migrator.MigrationStatusMigrated, baselined[0].Status)
+	require.True(t, baselined[0].Baselined)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+
+	since := time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC)
+	startedAt := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	mock.
+		ExpectQuery(`SELECT id, migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, COALESCE(error, '') AS error
+		FROM public.dbmigrations_events
+		WHERE migration = ? AND started_at >= ?
+		ORDER BY id`).
+		WithArgs("migration_1.sql", since).
+		WillReturnRows(
+			sqlmock.NewRows([]string{
+				"id", "migration", "direction", "from_status", "to_status", "started_at", "finished_at", "duration_ms",
+				"os_user", "hostname", "tool_version", "error",
+			}).
+				AddRow(1, "migration_1.sql", "up", "new", "migrated", startedAt, startedAt, 10, "deploy", "pod-1", "v1.0.0", "").
+				AddRow(2, "migration_1.sql", "down", "migrated", "new", startedAt, startedAt, 5, "deploy", "pod-1", "v1.0.0", ""),
+		)
+
+	events, err := pgMigrator.GetEvents(
+		context.Background(),
+		migrator.EventFilter{Migration: "migration_1.sql", Since: since},
+	)
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, migrator.MigrationDirectionUp, events[0].Direction)
+	require.Equal(t, migrator.MigrationStatusMigrated, events[0].ToStatus)
+	require.Equal(t, migrator.MigrationDirectionDown, events[1].Direction)
+	require.Equal(t, "deploy", events[1].OSUser)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+	ctx := context.Background()
+
+	expectSetApplicationName(mock)
+	mock.
+		ExpectQuery("SELECT pg_try_advisory_lock(hashtext($1), 0)").
+		WithArgs("public.dbmigrations").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.
+		ExpectExec("SELECT pg_advisory_unlock(hashtext($1), 0)").
+		WithArgs("public.dbmigrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RESET application_name").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	require.Nil(t, pgMigrator.Lock(ctx))
+	require.Nil(t, pgMigrator.Unlock(ctx))
+	// Повторное снятие блокировки ничего не делает.
+	require.Nil(t, pgMigrator.Unlock(ctx))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockHeld(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "")
+	pgMigrator.db = db
+
+	expectSetApplicationName(mock)
+	mock.
+		ExpectQuery("SELECT pg_try_advisory_lock(hashtext($1), 0)").
+		WithArgs("public.dbmigrations").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.
+		ExpectQuery(`SELECT a.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), '')
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory'
+		AND l.granted
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND l.classid::bigint = (hashtext($1)::bigint & 4294967295)
+		AND l.objid = $2
+		AND l.objsubid = 2`).
+		WithArgs("public.dbmigrations", int64(0)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"pid", "application_name", "client_addr"}).
+				AddRow(42, "gomigrator:7@pod-1", "10.0.0.1"),
+		)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = pgMigrator.Lock(ctx)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err, "another migrator is running (pid 7 on host pod-1)")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrationLockHeld(t *testing.T) {
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgMigrator := New("testdsn", "", "", migrator.WithNoWait(true))
+	pgMigrator.db = db
+	migration := migrator.Migration{FilePath: "./migrations/foo.sql", Name: "foo.sql"}
+
+	mock.
+		ExpectExec(`INSERT INTO public.dbmigrations (file_path, file_name, status, create_dt, migrate_dt) 
+		 VALUES (?, ?, ?, NOW(), NOW())`).
+		WithArgs(migration.FilePath, migration.Name, migrator.MigrationStatusNew).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery("SELECT id FROM public.dbmigrations WHERE file_name = $1").
+		WithArgs(migration.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.
+		ExpectQuery("SELECT pg_try_advisory_lock(hashtext($1), $2)").
+		WithArgs("public.dbmigrations", int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.
+		ExpectQuery(`SELECT a.pid, COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), '')
+		FROM pg_locks l
+		JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory'
+		AND l.granted
+		AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND l.classid::bigint = (hashtext($1)::bigint & 4294967295)
+		AND l.objid = $2
+		AND l.objsubid = 2`).
+		WithArgs("public.dbmigrations", int64(3)).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"pid", "application_name", "client_addr"}).
+				AddRow(42, "gomigrator:7@pod-1", "10.0.0.1"),
+		)
+
+	// С NoWait мигратор не ждёт блокировку миграции, которую держит другой мигратор.
+	err = pgMigrator.SetStatus(context.Background(), migration, migrator.MigrationDirectionMark, migrator.MigrationStatusNew)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.ErrorContains(t, err, "pid 7 on host pod-1")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
 }
 
 func getDBMock() (*sqlx.DB, sqlmock.Sqlmock, error) {
@@ -272,6 +834,13 @@ func expectStatusCheckError(mock sqlmock.Sqlmock, migrationFileName string) {
 		WillReturnError(errors.New("no rows"))
 }
 
+func expectMigrationStatus(mock sqlmock.Sqlmock, migrationFileName string, status migrator.MigrationStatus) {
+	mock.
+		ExpectQuery("SELECT status FROM public.dbmigrations WHERE file_name = ?").
+		WithArgs(migrationFileName).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+}
+
 func expectLock(mock sqlmock.Sqlmock, migrationFilePath, migrationFileName string) {
 	mock.
 		ExpectExec(`INSERT INTO public.dbmigrations (file_path, file_name, status, create_dt, migrate_dt) 
@@ -280,15 +849,19 @@ func expectLock(mock sqlmock.Sqlmock, migrationFilePath, migrationFileName strin
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.
-		ExpectExec("SELECT pg_advisory_lock(id) FROM public.dbmigrations WHERE file_name = ?").
+		ExpectQuery("SELECT id FROM public.dbmigrations WHERE file_name = $1").
 		WithArgs(migrationFileName).
-		WillReturnResult(sqlmock.NewResult(0, 0))
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.
+		ExpectQuery("SELECT pg_try_advisory_lock(hashtext($1), $2)").
+		WithArgs("public.dbmigrations", int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
 }
 
-func expectUnlock(mock sqlmock.Sqlmock, migrationFileName string) {
+func expectUnlock(mock sqlmock.Sqlmock) {
 	mock.
-		ExpectExec("SELECT pg_advisory_unlock(id) FROM public.dbmigrations WHERE file_name = ?").
-		WithArgs(migrationFileName).
+		ExpectExec("SELECT pg_advisory_unlock(hashtext($1), $2)").
+		WithArgs("public.dbmigrations", int64(1)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 }
 
@@ -301,8 +874,100 @@ func expectUpdateMigrationStatus(
 		ExpectExec(`UPDATE public.dbmigrations 
 		 SET
 			status = ?,
-		 	migrate_dt = NOW()
+		 	migrate_dt = NOW(),
+			baselined = FALSE
 		 WHERE file_name = ?`).
 		WithArgs(status, migrationFileName).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 }
+
+func expectSetApplicationName(mock sqlmock.Sqlmock) {
+	mock.
+		ExpectQuery("SELECT set_config('application_name', $1, false), pg_backend_pid()").
+		WithArgs(migrator.CurrentOwner().ApplicationName()).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"set_config", "pg_backend_pid"}).
+				AddRow(migrator.CurrentOwner().ApplicationName(), 42),
+		)
+}
+
+func expectStartMigration(mock sqlmock.Sqlmock, migrationFileName string) {
+	mock.
+		ExpectExec(`UPDATE public.dbmigrations
+		SET
+			status = ?,
+			migrate_dt = NOW(),
+			owner_pid = COALESCE(?, pg_backend_pid()),
+			owner_host = ?,
+			started_at = NOW()
+		WHERE file_name = ?`).
+		WithArgs(migrator.MigrationStatusMigrating, nil, migrator.CurrentOwner().Host, migrationFileName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectFinishMigration(
+	mock sqlmock.Sqlmock,
+	migrationFileName string,
+	status migrator.MigrationStatus,
+	errorMessage,
+	errorCode interface{},
+) {
+	expectFinishMigrationWithChecksum(mock, migrationFileName, status, "", errorMessage, errorCode)
+}
+
+func expectFinishMigrationWithChecksum(
+	mock sqlmock.Sqlmock,
+	migrationFileName string,
+	status migrator.MigrationStatus,
+	checksum string,
+	errorMessage,
+	errorCode interface{},
+) {
+	mock.
+		ExpectExec(`UPDATE public.dbmigrations
+		SET
+			status = ?,
+			migrate_dt = NOW(),
+			checksum = COALESCE(NULLIF(?, ''), checksum),
+			duration_ms = ?,
+			error_message = ?,
+			error_code = ?,
+			error_statement = ?,
+			baselined = FALSE
+		WHERE file_name = ?`).
+		WithArgs(status, checksum, sqlmock.AnyArg(), errorMessage, errorCode, nil, migrationFileName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectRecordEvent(
+	mock sqlmock.Sqlmock,
+	migrationFileName string,
+	direction migrator.MigrationDirection,
+	fromStatus,
+	toStatus migrator.MigrationStatus,
+	errorMessage string,
+) {
+	mock.
+		ExpectExec(`INSERT INTO public.dbmigrations_events (
+			migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, error
+		)
+		VALUES (
+			?, ?, ?, ?, ?, ?, ?,
+			?, ?, ?, NULLIF(?, '')
+		)`).
+		WithArgs(
+			migrationFileName,
+			direction,
+			fromStatus,
+			toStatus,
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			migrator.CurrentOwner().Host,
+			"",
+			errorMessage,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
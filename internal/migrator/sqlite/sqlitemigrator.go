@@ -0,0 +1,1042 @@
+package sqlitemigrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/gomigrator/pkg/migrator"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	DefaultHistoryTable = "dbmigrations"
+	// mainSchema is the only schema supported for migrations log table.
+	mainSchema = "main"
+)
+
+// defaultBusyTimeout is a time of waiting for write lock of database held by another connection
+// if lock timeout isn't set. Run lock is awaited without limit anyway, since SQLITE_BUSY is retried.
+const defaultBusyTimeout = 10 * time.Second
+
+var identifierRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type SQLiteMigrator struct {
+	dsn              string
+	migrationsSchema string
+	migratinsTable   string
+	mu               sync.Mutex
+	db               *sqlx.DB
+	// externalDB is set if db was passed with migrator.WithDB and must not be closed.
+	externalDB bool
+	logger     *slog.Logger
+	// lockOwner is set while migrator holds run lock.
+	lockOwner string
+	// toolVersion is recorded in migrations event log.
+	toolVersion string
+	// lockTimeout and noWait limit waiting for run lock and write lock of database.
+	lockTimeout time.Duration
+	noWait      bool
+}
+
+// New returns SQLite migrator for database file passed as dsn, e.g. "./data.db", "file:data.db?cache=shared"
+// or "sqlite://./data.db".
+// Migrations log is kept in historyTable of main schema, empty historyTable is replaced with DefaultHistoryTable.
+// Connection pool passed with migrator.WithDB must be opened with _txlock=immediate parameter,
+// otherwise concurrent migrators may fail with SQLITE_BUSY error.
+func New(dsn, historySchema, historyTable string, opts ...migrator.Option) *SQLiteMigrator {
+	options := migrator.NewOptions(opts...)
+
+	if historyTable == "" {
+		historyTable = DefaultHistoryTable
+	}
+
+	m := &SQLiteMigrator{
+		dsn:              trimScheme(dsn),
+		migrationsSchema: historySchema,
+		migratinsTable:   historyTable,
+		logger:           options.Logger,
+		toolVersion:      options.ToolVersion,
+		lockTimeout:      options.LockTimeout,
+		noWait:           options.NoWait,
+	}
+	if options.DB != nil {
+		m.db = sqlx.NewDb(options.DB, "sqlite")
+		m.externalDB = true
+	}
+
+	return m
+}
+
+func (m *SQLiteMigrator) Connect(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.db != nil {
+		// already connected
+		return nil
+	}
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite", withConnectionParams(m.dsn, m.connectionParams()))
+	if err != nil {
+		return err
+	}
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.db = db
+
+	return nil
+}
+
+// trimScheme removes sqlite:// scheme of DSN, scheme is case insensitive like in DSN scheme detection.
+func trimScheme(dsn string) string {
+	scheme, path, found := strings.Cut(dsn, "://")
+	if found && strings.EqualFold(scheme, "sqlite") {
+		return path
+	}
+
+	return dsn
+}
+
+// connectionParams returns parameters added to DSN: transactions take write lock of database on start
+// and concurrent writers wait for the lock within lock timeout instead of failing with SQLITE_BUSY.
+// With no-wait writers don't wait for the lock.
+func (m *SQLiteMigrator) connectionParams() []string {
+	busyTimeout := defaultBusyTimeout
+	if m.noWait {
+		busyTimeout = 0
+	} else if m.lockTimeout > 0 {
+		busyTimeout = m.lockTimeout
+	}
+
+	return []string{
+		"_txlock=immediate",
+		fmt.Sprintf("_pragma=busy_timeout(%d)", busyTimeout.Milliseconds()),
+	}
+}
+
+// withConnectionParams adds params to DSN, params already set in DSN are kept.
+func withConnectionParams(dsn string, params []string) string {
+	for _, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if strings.Contains(dsn, name+"=") {
+			continue
+		}
+
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + param
+		} else {
+			dsn += "?" + param
+		}
+	}
+
+	return dsn
+}
+
+func (m *SQLiteMigrator) Close() error {
+	if m.db == nil || m.externalDB {
+		return nil
+	}
+
+	err := m.db.Close()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.db = nil
+	return nil
+}
+
+func (m *SQLiteMigrator) Init(ctx context.Context) error {
+	if m.migrationsSchema != "" && m.migrationsSchema != mainSchema {
+		return fmt.Errorf("invalid migrations log schema %q: only %q schema is supported by SQLite", m.migrationsSchema, mainSchema)
+	}
+	if !identifierRe.MatchString(m.migratinsTable) {
+		return fmt.Errorf(
+			"invalid migrations log table name %q: only latin letters, digits and underscores are allowed",
+			m.migratinsTable,
+		)
+	}
+
+	_, err := m.db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS `+m.migratinsTable+` (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			file_path TEXT NOT NULL,
+			file_name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			create_dt DATETIME NOT NULL,
+			migrate_dt DATETIME NOT NULL,
+			CONSTRAINT unq_`+m.migratinsTable+`_file_name UNIQUE (file_name)
+		)`,
+	)
+	if err != nil {
+		return fmt.Errorf("error while create migrations log table: %w", err)
+	}
+
+	// SQLite не поддерживает advisory-блокировки, поэтому блокировка запуска хранится в отдельной таблице.
+	_, err = m.db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS `+m.lockTable()+` (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			owner TEXT NOT NULL,
+			locked_at DATETIME NOT NULL
+		)`,
+	)
+	if err != nil {
+		return fmt.Errorf("error while create run lock table: %w", err)
+	}
+
+	_, err = m.db.ExecContext(
+		ctx,
+		`CREATE TABLE IF NOT EXISTS `+m.eventsTable()+` (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			migration TEXT NOT NULL,
+			direction TEXT NOT NULL,
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			os_user TEXT NOT NULL,
+			hostname TEXT NOT NULL,
+			tool_version TEXT NOT NULL,
+			error TEXT NULL
+		)`,
+	)
+	if err != nil {
+		return fmt.Errorf("error while create migrations event log table: %w", err)
+	}
+
+	return m.upgradeMigrationsTable(ctx)
+}
+
+func (m *SQLiteMigrator) lockTable() string {
+	return m.migratinsTable + "_lock"
+}
+
+func (m *SQLiteMigrator) eventsTable() string {
+	return m.migratinsTable + "_events"
+}
+
+// Lock takes run lock by inserting the only row of lock table.
+// Lock of a crashed migrator on the same host is released once its process is gone,
+// lock of a crashed migrator on another host must be deleted manually.
+func (m *SQLiteMigrator) Lock(ctx context.Context) error {
+	return m.runLock(ctx, true)
+}
+
+// TryLock takes run lock if it is free.
+func (m *SQLiteMigrator) TryLock(ctx context.Context) error {
+	return m.runLock(ctx, false)
+}
+
+func (m *SQLiteMigrator) runLock(ctx context.Context, wait bool) error {
+	owner := migrator.CurrentOwner().String()
+	err := migrator.PollLock(
+		ctx,
+		wait,
+		func(ctx context.Context) (bool, error) {
+			locked, err := m.insertLock(ctx, owner)
+			if err != nil || locked {
+				return locked, ignoreBusy(err)
+			}
+
+			released, err := m.releaseStaleLock(ctx)
+			if err != nil || !released {
+				return false, ignoreBusy(err)
+			}
+
+			locked, err = m.insertLock(ctx, owner)
+			return locked, ignoreBusy(err)
+		},
+		m.lockHolder,
+	)
+	if err != nil {
+		return err
+	}
+
+	m.lockOwner = owner
+	return nil
+}
+
+// insertLock inserts the only row of lock table if it doesn't exist.
+func (m *SQLiteMigrator) insertLock(ctx context.Context, owner string) (bool, error) {
+	res, err := m.db.ExecContext(
+		ctx,
+		`INSERT INTO `+m.lockTable()+` (id, owner, locked_at) VALUES (1, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (id) DO NOTHING`,
+		owner,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := res.RowsAffected()
+	return inserted == 1, err
+}
+
+// releaseStaleLock deletes run lock left by crashed migrator whose process is no longer running.
+// Returns true if the lock was deleted.
+func (m *SQLiteMigrator) releaseStaleLock(ctx context.Context) (bool, error) {
+	holder := m.lockHolder(ctx)
+	owner, ok := migrator.ParseOwner(holder)
+	if !ok {
+		return false, nil
+	}
+
+	alive, known := owner.Alive()
+	if alive || !known {
+		return false, nil
+	}
+
+	// Владелец удаляется вместе с условием, чтобы не снять блокировку, которую уже перехватил другой мигратор.
+	res, err := m.db.ExecContext(ctx, `DELETE FROM `+m.lockTable()+` WHERE id = 1 AND owner = ?`, holder)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if deleted == 1 {
+		m.logger.WarnContext(ctx, "Release run lock of stopped migrator", "owner", holder)
+	}
+
+	return deleted == 1, nil
+}
+
+// ignoreBusy drops SQLITE_BUSY error: database is locked by another migrator, so run lock is considered held
+// and is retried by PollLock within lock timeout.
+func ignoreBusy(err error) error {
+	var e *sqlite.Error
+	if errors.As(err, &e) && e.Code()&0xff == sqlite3.SQLITE_BUSY {
+		return nil
+	}
+
+	return err
+}
+
+// lockHolder returns owner of run lock.
+func (m *SQLiteMigrator) lockHolder(ctx context.Context) string {
+	var owner string
+	err := m.db.QueryRowxContext(ctx, `SELECT owner FROM `+m.lockTable()+` WHERE id = 1`).Scan(&owner)
+	if err != nil {
+		return ""
+	}
+
+	return owner
+}
+
+// Unlock releases run lock taken by Lock.
+func (m *SQLiteMigrator) Unlock(ctx context.Context) error {
+	if m.lockOwner == "" {
+		return nil
+	}
+
+	_, err := m.db.ExecContext(ctx, `DELETE FROM `+m.lockTable()+` WHERE id = 1 AND owner = ?`, m.lockOwner)
+	if err != nil {
+		return fmt.Errorf("error while release run lock: %w", err)
+	}
+	m.lockOwner = ""
+
+	return nil
+}
+
+// Initialized reports whether migrations log table exists.
+func (m *SQLiteMigrator) Initialized(ctx context.Context) (bool, error) {
+	var migrationsTableExists bool
+	err := m.db.QueryRowxContext(
+		ctx,
+		`SELECT EXISTS (SELECT * FROM sqlite_master WHERE type = 'table' AND name = ?) as table_exists`,
+		m.migratinsTable,
+	).Scan(&migrationsTableExists)
+	if err != nil {
+		return false, fmt.Errorf("error while scan existence row: %w", err)
+	}
+
+	return migrationsTableExists, nil
+}
+
+// historyTableUpgrades adds columns missing in migrations log tables created by previous versions.
+// SQLite doesn't support ADD COLUMN IF NOT EXISTS, so columns are checked before adding.
+var historyTableUpgrades = []struct {
+	column     string
+	definition string
+}{
+	{column: "checksum", definition: "TEXT NULL"},
+	{column: "owner_pid", definition: "INTEGER NULL"},
+	{column: "owner_host", definition: "TEXT NULL"},
+	{column: "started_at", definition: "DATETIME NULL"},
+	{column: "duration_ms", definition: "INTEGER NULL"},
+	{column: "error_message", definition: "TEXT NULL"},
+	{column: "error_code", definition: "TEXT NULL"},
+	{column: "error_statement", definition: "INTEGER NULL"},
+	{column: "baselined", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+}
+
+func (m *SQLiteMigrator) upgradeMigrationsTable(ctx context.Context) error {
+	for _, upgrade := range historyTableUpgrades {
+		var columnExists bool
+		err := m.db.QueryRowxContext(
+			ctx,
+			`SELECT EXISTS (SELECT * FROM pragma_table_info(?) WHERE name = ?) as column_exists`,
+			m.migratinsTable,
+			upgrade.column,
+		).Scan(&columnExists)
+		if err != nil {
+			return fmt.Errorf("error while upgrade migrations log table: %w", err)
+		}
+		if columnExists {
+			continue
+		}
+
+		_, err = m.db.ExecContext(
+			ctx,
+			`ALTER TABLE `+m.migratinsTable+` ADD COLUMN `+upgrade.column+` `+upgrade.definition,
+		)
+		if err != nil {
+			return fmt.Errorf("error while upgrade migrations log table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Up applies migrations and returns migrations which were applied by this call.
+func (m *SQLiteMigrator) Up(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	applied := make([]migrator.Migration, 0, len(migrations))
+	for i := range migrations {
+		m.logger.InfoContext(ctx, "Start migration", "migration", migrations[i].Name)
+
+		if isMigrated(ctx, m.db, m.migratinsTable, migrations[i].Name) {
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
+			continue
+		}
+
+		migrated, err := m.ApplyMigration(
+			ctx,
+			migrations[i],
+			migrator.MigrationDirectionUp,
+			migrator.MigrationStatusMigrated,
+			migrator.MigrationStatusFailed,
+			func(status migrator.MigrationStatus) bool {
+				// Если взяли lock, надо ещё раз проверить, что миграция ещё не выполнена.
+				return !isMigratedStatus(status)
+			},
+		)
+		if err != nil {
+			return applied, fmt.Errorf("error while up migration %s: %w", migrations[i].Name, err)
+		}
+		if migrated {
+			m.logger.InfoContext(ctx, "Success migration", "migration", migrations[i].Name)
+			migration := migrations[i]
+			migration.Status = migrator.MigrationStatusMigrated
+			applied = append(applied, migration)
+		} else {
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
+		}
+	}
+	return applied, nil
+}
+
+// Down rollbacks migrations and returns migrations which were rolled back by this call.
+func (m *SQLiteMigrator) Down(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	rolledBack := make([]migrator.Migration, 0, len(migrations))
+	for i := range migrations {
+		m.logger.InfoContext(ctx, "Start rollback", "migration", migrations[i].Name)
+
+		_, err := m.ApplyMigration(
+			ctx,
+			migrations[i],
+			migrator.MigrationDirectionDown,
+			migrator.MigrationStatusNew,
+			migrator.MigrationStatusMigrated,
+			nil,
+		)
+		if err != nil {
+			return rolledBack, fmt.Errorf("error while rollback migration %s: %w", migrations[i].Name, err)
+		}
+
+		m.logger.InfoContext(ctx, "Success rollback", "migration", migrations[i].Name)
+		migration := migrations[i]
+		migration.Status = migrator.MigrationStatusNew
+		rolledBack = append(rolledBack, migration)
+	}
+	return rolledBack, nil
+}
+
+// ApplyMigration applies migration in passed direction.
+// Migration and its status are written in one transaction started with BEGIN IMMEDIATE,
+// which holds write lock of database, so concurrent migrators wait for each other.
+// Migrations without transaction are run on a dedicated connection without the lock.
+func (m *SQLiteMigrator) ApplyMigration(
+	ctx context.Context,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+	successStatus,
+	failStatus migrator.MigrationStatus,
+	afterLockCheck func(status migrator.MigrationStatus) bool,
+) (bool, error) {
+	if migration.NoTransaction {
+		return m.applyMigrationNoTx(ctx, migration, direction, successStatus)
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error while locking database: %w", err)
+	}
+	defer tx.Rollback()
+
+	fromStatus, err := migrationStatus(ctx, tx, m.migratinsTable, migration.Name)
+	if err != nil {
+		return false, err
+	}
+
+	if afterLockCheck != nil {
+		if !afterLockCheck(fromStatus) {
+			return false, nil
+		}
+	}
+
+	started := time.Now()
+	if direction == migrator.MigrationDirectionUp {
+		err = migration.UpHandlerContext(ctx, tx)
+	} else {
+		err = migration.DownHandlerContext(ctx, tx)
+	}
+	if err != nil {
+		tx.Rollback()
+		event := migrator.NewEvent(migration, direction, fromStatus, failStatus, started, err)
+		updateStatusErr := m.finishMigration(ctx, m.db, migration, event, err)
+		if updateStatusErr != nil {
+			return false, updateStatusErr
+		}
+		return false, err
+	}
+
+	event := migrator.NewEvent(migration, direction, fromStatus, successStatus, started, nil)
+	err = m.finishMigration(ctx, tx, migration, event, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (m *SQLiteMigrator) applyMigrationNoTx(
+	ctx context.Context,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+	successStatus migrator.MigrationStatus,
+) (bool, error) {
+	fromStatus, err := migrationStatus(ctx, m.db, m.migratinsTable, migration.Name)
+	if err != nil {
+		return false, err
+	}
+
+	err = m.startMigration(ctx, migration)
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := m.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	started := time.Now()
+	if direction == migrator.MigrationDirectionUp {
+		err = migration.UpHandlerNoTxContext(ctx, conn)
+	} else {
+		err = migration.DownHandlerNoTxContext(ctx, conn)
+	}
+	if err != nil {
+		// Без транзакции изменения могли примениться частично, поэтому миграция всегда помечается как failed.
+		event := migrator.NewEvent(migration, direction, fromStatus, migrator.MigrationStatusFailed, started, err)
+		updateStatusErr := m.finishMigration(ctx, m.db, migration, event, err)
+		if updateStatusErr != nil {
+			return false, updateStatusErr
+		}
+		return false, fmt.Errorf("migration without transaction may be partially applied: %w", err)
+	}
+
+	event := migrator.NewEvent(migration, direction, fromStatus, successStatus, started, nil)
+	err = m.finishMigration(ctx, m.db, migration, event, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetStatus records status of migration without applying it. Status is updated in transaction
+// holding write lock of database, so it doesn't interleave with applying migrations.
+func (m *SQLiteMigrator) SetStatus(
+	ctx context.Context,
+	migration migrator.Migration,
+	direction migrator.MigrationDirection,
+	status migrator.MigrationStatus,
+) error {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error while locking database: %w", err)
+	}
+	defer tx.Rollback()
+
+	started := time.Now()
+	fromStatus, err := migrationStatus(ctx, tx, m.migratinsTable, migration.Name)
+	if err != nil {
+		return fmt.Errorf("error while set migration status: %w", err)
+	}
+
+	err = m.updateMigrationStatus(ctx, tx, migration, status)
+	if err != nil {
+		return fmt.Errorf("error while set migration status: %w", err)
+	}
+
+	err = m.recordEvent(ctx, tx, migrator.NewEvent(migration, direction, fromStatus, status, started, nil))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Baseline records migrations as migrated without applying them. Migrations are recorded
+// in one transaction holding write lock of database, so either all of them are baselined or none.
+func (m *SQLiteMigrator) Baseline(ctx context.Context, migrations []migrator.Migration) ([]migrator.Migration, error) {
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while locking database: %w", err)
+	}
+	defer tx.Rollback()
+
+	baselined := make([]migrator.Migration, 0, len(migrations))
+	for i := range migrations {
+		started := time.Now()
+		fromStatus, err := migrationStatus(ctx, tx, m.migratinsTable, migrations[i].Name)
+		if err != nil {
+			return nil, fmt.Errorf("error while baseline migration %s: %w", migrations[i].Name, err)
+		}
+		if isMigratedStatus(fromStatus) {
+			m.logger.InfoContext(ctx, "Skip migration", "migration", migrations[i].Name)
+			continue
+		}
+
+		_, err = tx.NamedExecContext(
+			ctx,
+			`INSERT INTO `+m.migratinsTable+` (file_path, file_name, status, create_dt, migrate_dt, checksum, baselined)
+			VALUES (
+				:migration_filepath, :migration_filename, :migration_status, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP,
+				NULLIF(:checksum, ''), TRUE
+			)
+			ON CONFLICT (file_name) DO UPDATE SET
+				status = excluded.status,
+				migrate_dt = CURRENT_TIMESTAMP,
+				checksum = COALESCE(excluded.checksum, checksum),
+				duration_ms = NULL,
+				error_message = NULL,
+				error_code = NULL,
+				error_statement = NULL,
+				baselined = TRUE`,
+			map[string]interface{}{
+				"migration_filepath": migrations[i].FilePath,
+				"migration_filename": migrations[i].Name,
+				"migration_status":   migrator.MigrationStatusMigrated,
+				"checksum":           migrations[i].Checksum,
+			},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error while baseline migration %s: %w", migrations[i].Name, err)
+		}
+
+		event := migrator.NewEvent(
+			migrations[i],
+			migrator.MigrationDirectionBaseline,
+			fromStatus,
+			migrator.MigrationStatusMigrated,
+			started,
+			nil,
+		)
+		err = m.recordEvent(ctx, tx, event)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations[i].Status = migrator.MigrationStatusMigrated
+		migrations[i].Baselined = true
+		baselined = append(baselined, migrations[i])
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return baselined, nil
+}
+
+// SetChecksum records checksum of migration file.
+func (m *SQLiteMigrator) SetChecksum(ctx context.Context, migrationName, checksum string) error {
+	return setChecksum(ctx, m.db, m.migratinsTable, migrationName, checksum)
+}
+
+func setChecksum(ctx context.Context, e sqlx.ExtContext, table, migrationName, checksum string) error {
+	_, err := sqlx.NamedExecContext(
+		ctx,
+		e,
+		`UPDATE `+table+` SET checksum = :checksum WHERE file_name = :migration_filename`,
+		map[string]interface{}{
+			"migration_filename": migrationName,
+			"checksum":           checksum,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error while set migration checksum: %w", err)
+	}
+
+	return nil
+}
+
+// selectMigrations returns query selecting migrations from migrations log, rows are read with scanMigrations.
+// owner_locked reports whether owner of migration holds run lock,
+// owners in lock table are formatted with migrator.Owner.String.
+func (m *SQLiteMigrator) selectMigrations() string {
+	return `SELECT id, file_path, file_name, status, create_dt, migrate_dt, COALESCE(checksum, '') AS checksum,
+			owner_pid, COALESCE(owner_host, '') AS owner_host, started_at,
+			duration_ms, COALESCE(error_message, '') AS error_message, COALESCE(error_code, '') AS error_code, error_statement,
+			baselined,
+			EXISTS (
+				SELECT * FROM ` + m.lockTable() + ` l WHERE l.owner = 'pid ' || owner_pid || ' on host ' || owner_host
+			) AS owner_locked
+		FROM ` + m.migratinsTable
+}
+
+// migrationRow is a row of query returned by selectMigrations.
+type migrationRow struct {
+	migrator.Migration
+	OwnerLocked bool `db:"owner_locked"`
+}
+
+// scanMigrations reads migrations selected by selectMigrations.
+func scanMigrations(rows *sqlx.Rows) ([]migrator.Migration, error) {
+	migrations := []migrator.Migration{}
+	for rows.Next() {
+		var row migrationRow
+		err := rows.StructScan(&row)
+		if err != nil {
+			return nil, err
+		}
+
+		row.Orphaned = row.orphaned()
+		migrations = append(migrations, row.Migration)
+	}
+
+	return migrations, rows.Err()
+}
+
+// orphaned reports whether migration is left in migrating status by stopped migrator.
+// SQLite has no sessions, so migration is orphaned if its owner process on the same host is no longer running.
+// Processes of other hosts can't be checked, their migration is orphaned if the owner doesn't hold run lock.
+func (r migrationRow) orphaned() bool {
+	if r.Status != migrator.MigrationStatusMigrating || r.OwnerPID == nil {
+		return false
+	}
+
+	owner := migrator.Owner{PID: int(*r.OwnerPID), Host: r.OwnerHost}
+	alive, known := owner.Alive()
+	if known {
+		return !alive
+	}
+
+	return !r.OwnerLocked
+}
+
+func (m *SQLiteMigrator) GetLastMigations(
+	ctx context.Context,
+	status migrator.MigrationStatus,
+	count int,
+) ([]migrator.Migration, error) {
+	rows, err := m.db.NamedQueryContext(
+		ctx,
+		m.selectMigrations()+`
+		WHERE status = :migration_status
+		ORDER BY id DESC
+		LIMIT :count`,
+		map[string]interface{}{
+			"migration_status": status,
+			"count":            count,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMigrations(rows)
+}
+
+func (m *SQLiteMigrator) GetMigations(ctx context.Context) ([]migrator.Migration, error) {
+	rows, err := m.db.QueryxContext(
+		ctx,
+		m.selectMigrations()+`
+		ORDER BY id DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMigrations(rows)
+}
+
+func isMigrated(ctx context.Context, q sqlx.QueryerContext, table, migrationFileName string) bool {
+	status, err := migrationStatus(ctx, q, table, migrationFileName)
+	if err != nil {
+		return false
+	}
+
+	return isMigratedStatus(status)
+}
+
+func isMigratedStatus(status migrator.MigrationStatus) bool {
+	return status == migrator.MigrationStatusMigrated || status == migrator.MigrationStatusMigrating
+}
+
+// migrationStatus returns status of migration, migrations missing in migrations log have new status.
+func migrationStatus(
+	ctx context.Context,
+	q sqlx.QueryerContext,
+	table,
+	migrationFileName string,
+) (migrator.MigrationStatus, error) {
+	var status migrator.MigrationStatus
+	err := q.QueryRowxContext(
+		ctx,
+		"SELECT status FROM "+table+" WHERE file_name = ?",
+		migrationFileName,
+	).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return migrator.MigrationStatusNew, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return status, nil
+}
+
+// updateMigrationStatus registers migration in migrations log if needed and updates its status.
+func (m *SQLiteMigrator) updateMigrationStatus(
+	ctx context.Context,
+	e sqlx.ExtContext,
+	migration migrator.Migration,
+	migrationStatus migrator.MigrationStatus,
+) error {
+	_, err := sqlx.NamedExecContext(
+		ctx,
+		e,
+		`INSERT INTO `+m.migratinsTable+` (file_path, file_name, status, create_dt, migrate_dt)
+		VALUES (:migration_filepath, :migration_filename, :migration_status, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (file_name) DO UPDATE SET
+			status = excluded.status,
+			migrate_dt = CURRENT_TIMESTAMP,
+			baselined = FALSE`,
+		map[string]interface{}{
+			"migration_filepath": migration.FilePath,
+			"migration_filename": migration.Name,
+			"migration_status":   migrationStatus,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// startMigration registers migration in migrations log if needed, sets its migrating status
+// and records current process as its owner.
+func (m *SQLiteMigrator) startMigration(ctx context.Context, migration migrator.Migration) error {
+	owner := migrator.CurrentOwner()
+	_, err := m.db.NamedExecContext(
+		ctx,
+		`INSERT INTO `+m.migratinsTable+` (file_path, file_name, status, create_dt, migrate_dt, owner_pid, owner_host, started_at)
+		VALUES (
+			:migration_filepath, :migration_filename, :migration_status, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP,
+			:owner_pid, :owner_host, CURRENT_TIMESTAMP
+		)
+		ON CONFLICT (file_name) DO UPDATE SET
+			status = excluded.status,
+			migrate_dt = CURRENT_TIMESTAMP,
+			owner_pid = excluded.owner_pid,
+			owner_host = excluded.owner_host,
+			started_at = CURRENT_TIMESTAMP`,
+		map[string]interface{}{
+			"migration_filepath": migration.FilePath,
+			"migration_filename": migration.Name,
+			"migration_status":   migrator.MigrationStatusMigrating,
+			"owner_pid":          owner.PID,
+			"owner_host":         owner.Host,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// finishMigration registers migration in migrations log if needed, sets its final status,
+// records duration and error of applying and appends event to migrations event log.
+func (m *SQLiteMigrator) finishMigration(
+	ctx context.Context,
+	e sqlx.ExtContext,
+	migration migrator.Migration,
+	event migrator.Event,
+	migrationErr error,
+) error {
+	params := map[string]interface{}{
+		"migration_filepath": migration.FilePath,
+		"migration_filename": migration.Name,
+		"migration_status":   event.ToStatus,
+		"duration_ms":        event.DurationMS,
+		"error_message":      nil,
+		"error_code":         nil,
+		"error_statement":    migrator.StatementIndex(migrationErr),
+		"checksum":           migrator.AppliedChecksum(migration, event),
+	}
+	if migrationErr != nil {
+		params["error_message"] = migrationErr.Error()
+		params["error_code"] = errorCode(migrationErr)
+	}
+
+	// Контрольная сумма записывается тем же запросом, что и статус, чтобы применённая миграция не осталась без неё.
+	_, err := sqlx.NamedExecContext(
+		ctx,
+		e,
+		`INSERT INTO `+m.migratinsTable+` (
+			file_path, file_name, status, create_dt, migrate_dt, checksum,
+			duration_ms, error_message, error_code, error_statement
+		)
+		VALUES (
+			:migration_filepath, :migration_filename, :migration_status, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP,
+			NULLIF(:checksum, ''), :duration_ms, :error_message, :error_code, :error_statement
+		)
+		ON CONFLICT (file_name) DO UPDATE SET
+			status = excluded.status,
+			migrate_dt = CURRENT_TIMESTAMP,
+			checksum = COALESCE(excluded.checksum, checksum),
+			duration_ms = excluded.duration_ms,
+			error_message = excluded.error_message,
+			error_code = excluded.error_code,
+			error_statement = excluded.error_statement,
+			baselined = FALSE`,
+		params,
+	)
+	if err != nil {
+		return err
+	}
+
+	return m.recordEvent(ctx, e, event)
+}
+
+// recordEvent appends event to migrations event log.
+func (m *SQLiteMigrator) recordEvent(ctx context.Context, e sqlx.ExtContext, event migrator.Event) error {
+	event.ToolVersion = m.toolVersion
+	_, err := sqlx.NamedExecContext(
+		ctx,
+		e,
+		`INSERT INTO `+m.eventsTable()+` (
+			migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, error
+		)
+		VALUES (
+			:migration, :direction, :from_status, :to_status, :started_at, :finished_at, :duration_ms,
+			:os_user, :hostname, :tool_version, NULLIF(:error, '')
+		)`,
+		event,
+	)
+	if err != nil {
+		return fmt.Errorf("error while record migration event: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvents returns events of migrations event log selected by filter in order they were recorded.
+func (m *SQLiteMigrator) GetEvents(ctx context.Context, filter migrator.EventFilter) ([]migrator.Event, error) {
+	conditions := []string{}
+	params := map[string]interface{}{}
+	if filter.Migration != "" {
+		conditions = append(conditions, "migration = :migration")
+		params["migration"] = filter.Migration
+	}
+	if !filter.Since.IsZero() {
+		// Время хранится строкой в UTC, поэтому сравнивается значение в том же формате.
+		conditions = append(conditions, "started_at >= :since")
+		params["since"] = filter.Since.UTC()
+	}
+
+	query := `SELECT id, migration, direction, from_status, to_status, started_at, finished_at, duration_ms,
+			os_user, hostname, tool_version, COALESCE(error, '') AS error
+		FROM ` + m.eventsTable()
+	if len(conditions) != 0 {
+		query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := m.db.NamedQueryContext(ctx, query+`
+		ORDER BY id`, params)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []migrator.Event{}
+	for rows.Next() {
+		var event migrator.Event
+		err = rows.StructScan(&event)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// errorCode returns name of SQLite primary result code, e.g. "SQLITE_CONSTRAINT",
+// or nil if err isn't returned by SQLite.
+func errorCode(err error) interface{} {
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return nil
+	}
+
+	// Описания кодов имеют вид "Generic error (SQLITE_ERROR)", имя кода берётся из скобок.
+	code := e.Code() & 0xff
+	description := sqlite.ErrorCodeString[code]
+	start, end := strings.LastIndex(description, "("), strings.LastIndex(description, ")")
+	if start == -1 || end < start {
+		return strconv.Itoa(code)
+	}
+
+	return description[start+1 : end]
+}
@@ -0,0 +1,360 @@
+package sqlitemigrator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/gomigrator/pkg/migrator"
+	"github.com/wursta/gomigrator/pkg/migrator/migratortest"
+)
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	sqliteMigrator := newTestMigrator(t, filepath.Join(t.TempDir(), "test.db"))
+
+	migrations := []migrator.Migration{
+		newTestMigration("1.sql", "CREATE TABLE foo (id INTEGER)", "DROP TABLE foo"),
+		newTestMigration("2.sql", "CREATE TABLE bar (id INTEGER)", "DROP TABLE bar"),
+	}
+	migrations[0].Checksum = "checksum"
+
+	applied, err := sqliteMigrator.Up(ctx, migrations)
+	require.Nil(t, err)
+	require.Len(t, applied, 2)
+
+	applied, err = sqliteMigrator.Up(ctx, migrations)
+	require.Nil(t, err)
+	require.Empty(t, applied)
+
+	dbMigrations, err := sqliteMigrator.GetMigations(ctx)
+	require.Nil(t, err)
+	require.Len(t, dbMigrations, 2)
+	require.Equal(t, "2.sql", dbMigrations[0].Name)
+	require.Equal(t, migrator.MigrationStatusMigrated, dbMigrations[0].Status)
+	require.Equal(t, "checksum", dbMigrations[1].Checksum)
+	require.False(t, dbMigrations[1].CreateDT.IsZero())
+
+	last, err := sqliteMigrator.GetLastMigations(ctx, migrator.MigrationStatusMigrated, 1)
+	require.Nil(t, err)
+	require.Len(t, last, 1)
+	require.Equal(t, "2.sql", last[0].Name)
+
+	rolledBack, err := sqliteMigrator.Down(ctx, migrations[1:])
+	require.Nil(t, err)
+	require.Len(t, rolledBack, 1)
+	require.False(t, tableExists(t, sqliteMigrator, "bar"))
+	require.True(t, tableExists(t, sqliteMigrator, "foo"))
+}
+
+func TestUpFailure(t *testing.T) {
+	ctx := context.Background()
+	sqliteMigrator := newTestMigrator(t, filepath.Join(t.TempDir(), "test.db"))
+
+	migration := newTestMigration("1.sql", "CREATE TABLE foo (id INTEGER)", "")
+	migration.UpHandlerContext = func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, "CREATE TABLE foo (id INTEGER)")
+		if err != nil {
+			return err
+		}
+		return errors.New("some DB error")
+	}
+
+	applied, err := sqliteMigrator.Up(ctx, []migrator.Migration{migration})
+	require.ErrorContains(t, err, "some DB error")
+	require.Empty(t, applied)
+	// DDL в SQLite транзакционный, поэтому таблица не должна остаться после неудачной миграции.
+	require.False(t, tableExists(t, sqliteMigrator, "foo"))
+
+	dbMigrations, err := sqliteMigrator.GetMigations(ctx)
+	require.Nil(t, err)
+	require.Len(t, dbMigrations, 1)
+	require.Equal(t, migrator.MigrationStatusFailed, dbMigrations[0].Status)
+}
+
+func TestConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	migrations := []migrator.Migration{
+		newTestMigration("1.sql", "CREATE TABLE foo (id INTEGER)", ""),
+		newTestMigration("2.sql", "CREATE TABLE bar (id INTEGER)", ""),
+	}
+
+	migrators := make([]*SQLiteMigrator, 5)
+	for i := range migrators {
+		migrators[i] = newTestMigrator(t, dsn)
+	}
+
+	var wg sync.WaitGroup
+	appliedCount := make([]int, len(migrators))
+	errs := make([]error, len(migrators))
+	for i := range migrators {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied, err := migrators[i].Up(ctx, migrations)
+			appliedCount[i] = len(applied)
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range migrators {
+		require.Nil(t, errs[i])
+		total += appliedCount[i]
+	}
+	require.Equal(t, len(migrations), total)
+}
+
+func TestInit(t *testing.T) {
+	ctx := context.Background()
+
+	sqliteMigrator := New(filepath.Join(t.TempDir(), "test.db"), "main", "migrations")
+	require.Nil(t, sqliteMigrator.Connect(ctx))
+	defer sqliteMigrator.Close()
+
+	initialized, err := sqliteMigrator.Initialized(ctx)
+	require.Nil(t, err)
+	require.False(t, initialized)
+
+	require.Nil(t, sqliteMigrator.Init(ctx))
+	// Повторная инициализация не должна падать.
+	require.Nil(t, sqliteMigrator.Init(ctx))
+	require.True(t, tableExists(t, sqliteMigrator, "migrations"))
+
+	initialized, err = sqliteMigrator.Initialized(ctx)
+	require.Nil(t, err)
+	require.True(t, initialized)
+
+	require.ErrorContains(t, New("test.db", "public", "").Init(ctx), `invalid migrations log schema "public"`)
+	require.ErrorContains(t, New("test.db", "", "migrations;").Init(ctx), "invalid migrations log table name")
+}
+
+func TestWithConnectionParams(t *testing.T) {
+	params := New("test.db", "", "").connectionParams()
+	require.Equal(
+		t,
+		"test.db?_txlock=immediate&_pragma=busy_timeout(10000)",
+		withConnectionParams("test.db", params),
+	)
+	require.Equal(
+		t,
+		"file:test.db?_pragma=busy_timeout(100)&_txlock=immediate",
+		withConnectionParams("file:test.db?_pragma=busy_timeout(100)", params),
+	)
+
+	// Ожидание блокировки БД ограничено параметрами ожидания блокировки запуска.
+	require.Equal(
+		t,
+		"test.db?_txlock=immediate&_pragma=busy_timeout(0)",
+		withConnectionParams("test.db", New("test.db", "", "", migrator.WithNoWait(true)).connectionParams()),
+	)
+	require.Equal(
+		t,
+		"test.db?_txlock=immediate&_pragma=busy_timeout(30000)",
+		withConnectionParams("test.db", New("test.db", "", "", migrator.WithLockTimeout(30*time.Second)).connectionParams()),
+	)
+}
+
+func TestRunLockOfBusyDatabase(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	newTestMigrator(t, dsn)
+
+	// Другой мигратор держит блокировку записи в БД.
+	writer := newTestMigrator(t, dsn)
+	tx, err := writer.db.BeginTxx(ctx, nil)
+	require.Nil(t, err)
+	defer tx.Rollback()
+
+	noWait := New(dsn, "", "", migrator.WithNoWait(true))
+	require.Nil(t, noWait.Connect(ctx))
+	defer noWait.Close()
+
+	started := time.Now()
+	err = noWait.TryLock(ctx)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.Less(t, time.Since(started), time.Second, "migrator must not wait for lock")
+
+	lockTimeout := 300 * time.Millisecond
+	timeout := New(dsn, "", "", migrator.WithLockTimeout(lockTimeout))
+	require.Nil(t, timeout.Connect(ctx))
+	defer timeout.Close()
+
+	lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	// SQLITE_BUSY означает занятую блокировку, поэтому она ожидается до конца таймаута.
+	err = timeout.Lock(lockCtx)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.Nil(t, tx.Rollback())
+	require.Nil(t, timeout.Lock(ctx))
+	require.Nil(t, timeout.Unlock(ctx))
+}
+
+func TestTrimScheme(t *testing.T) {
+	require.Equal(t, "./data.db", trimScheme("sqlite://./data.db"))
+	require.Equal(t, "./data.db", trimScheme("SQLite://./data.db"))
+	require.Equal(t, "file:data.db?cache=shared", trimScheme("file:data.db?cache=shared"))
+}
+
+func TestOrphanedMigration(t *testing.T) {
+	ctx := context.Background()
+	sqliteMigrator := newTestMigrator(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// Мигратор другого процесса упал, не завершив миграцию без транзакции.
+	_, err := sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations (file_path, file_name, status, create_dt, migrate_dt, owner_pid, owner_host, started_at)
+		VALUES ('./migrations/foo.sql', 'foo.sql', 'migrating', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 1, 'pod-1', CURRENT_TIMESTAMP)`,
+	)
+	require.Nil(t, err)
+
+	migrations, err := sqliteMigrator.GetMigations(ctx)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.True(t, migrations[0].Orphaned)
+	require.Equal(t, int64(1), *migrations[0].OwnerPID)
+	require.Equal(t, "pod-1", migrations[0].OwnerHost)
+	require.NotNil(t, migrations[0].StartedAt)
+
+	// Пока владелец держит блокировку запуска, миграция не считается брошенной.
+	_, err = sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations_lock (id, owner, locked_at) VALUES (1, 'pid 1 on host pod-1', CURRENT_TIMESTAMP)`,
+	)
+	require.Nil(t, err)
+
+	migrations, err = sqliteMigrator.GetLastMigations(ctx, migrator.MigrationStatusMigrating, 1)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.False(t, migrations[0].Orphaned)
+}
+
+func TestOrphanedMigrationOfStoppedProcess(t *testing.T) {
+	ctx := context.Background()
+	sqliteMigrator := newTestMigrator(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// Процесс с таким pid не может существовать: мигратор упал, а его блокировка запуска осталась.
+	stale := migrator.Owner{PID: math.MaxInt32, Host: migrator.CurrentOwner().Host}
+	_, err := sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations (file_path, file_name, status, create_dt, migrate_dt, owner_pid, owner_host, started_at)
+		VALUES ('./migrations/foo.sql', 'foo.sql', 'migrating', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?, ?, CURRENT_TIMESTAMP)`,
+		stale.PID,
+		stale.Host,
+	)
+	require.Nil(t, err)
+	_, err = sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations_lock (id, owner, locked_at) VALUES (1, ?, CURRENT_TIMESTAMP)`,
+		stale.String(),
+	)
+	require.Nil(t, err)
+
+	migrations, err := sqliteMigrator.GetMigations(ctx)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.True(t, migrations[0].Orphaned)
+
+	// Миграция живого процесса не считается брошенной, даже если он не держит блокировку запуска.
+	_, err = sqliteMigrator.db.ExecContext(ctx, `DELETE FROM dbmigrations_lock`)
+	require.Nil(t, err)
+	_, err = sqliteMigrator.db.ExecContext(ctx, `UPDATE dbmigrations SET owner_pid = ?`, os.Getpid())
+	require.Nil(t, err)
+
+	migrations, err = sqliteMigrator.GetMigations(ctx)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.False(t, migrations[0].Orphaned)
+}
+
+func TestStaleRunLock(t *testing.T) {
+	ctx := context.Background()
+	sqliteMigrator := newTestMigrator(t, filepath.Join(t.TempDir(), "test.db"))
+
+	// Процесс с таким pid не может существовать, значит мигратор упал, не сняв блокировку.
+	stale := migrator.Owner{PID: math.MaxInt32, Host: migrator.CurrentOwner().Host}
+	_, err := sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations_lock (id, owner, locked_at) VALUES (1, ?, CURRENT_TIMESTAMP)`,
+		stale.String(),
+	)
+	require.Nil(t, err)
+
+	require.Nil(t, sqliteMigrator.TryLock(ctx))
+	require.Equal(t, migrator.CurrentOwner().String(), sqliteMigrator.lockHolder(ctx))
+	require.Nil(t, sqliteMigrator.Unlock(ctx))
+
+	// Блокировку мигратора другого хоста проверить нельзя, она не снимается.
+	_, err = sqliteMigrator.db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations_lock (id, owner, locked_at) VALUES (1, ?, CURRENT_TIMESTAMP)`,
+		migrator.Owner{PID: math.MaxInt32, Host: "pod-1"}.String(),
+	)
+	require.Nil(t, err)
+
+	err = sqliteMigrator.TryLock(ctx)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.ErrorContains(t, err, "on host pod-1")
+}
+
+func newTestMigrator(t *testing.T, dsn string) *SQLiteMigrator {
+	t.Helper()
+
+	sqliteMigrator := New(dsn, "", "")
+	require.Nil(t, sqliteMigrator.Connect(context.Background()))
+	t.Cleanup(func() {
+		sqliteMigrator.Close()
+	})
+	require.Nil(t, sqliteMigrator.Init(context.Background()))
+
+	return sqliteMigrator
+}
+
+func newTestMigration(name, upQuery, downQuery string) migrator.Migration {
+	return migrator.Migration{
+		FilePath: "./migrations/" + name,
+		Name:     name,
+		UpHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, upQuery)
+			return err
+		},
+		DownHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, downQuery)
+			return err
+		},
+	}
+}
+
+func tableExists(t *testing.T, sqliteMigrator *SQLiteMigrator, table string) bool {
+	t.Helper()
+
+	var exists bool
+	err := sqliteMigrator.db.QueryRowx(
+		"SELECT EXISTS (SELECT * FROM sqlite_master WHERE type = 'table' AND name = ?)",
+		table,
+	).Scan(&exists)
+	require.Nil(t, err)
+
+	return exists
+}
+
+func TestConformance(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	migratortest.Run(t, func(*testing.T) migrator.Driver {
+		return New(dsn, "", "")
+	})
+}
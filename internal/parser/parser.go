@@ -1,23 +1,49 @@
 package parser
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/jmoiron/sqlx"
-	migrator "github.com/wursta/gomigrator/internal/migrator"
+	"github.com/wursta/gomigrator/internal/splitter"
+	migrator "github.com/wursta/gomigrator/pkg/migrator"
 )
 
-func ParseMigrations(migrationsDir string) ([]migrator.Migration, error) {
-	files, err := os.ReadDir(migrationsDir)
+const sqlMigrationFileExt = ".sql"
+
+// ParseMigrations parses SQL migration files of migrationsDir.
+// Statements of migrations are split according to SQL dialect of database.
+func ParseMigrations(migrationsDir string, dialect splitter.Dialect) ([]migrator.Migration, error) {
+	return ParseMigrationsFS(os.DirFS(migrationsDir), migrationsDir, dialect)
+}
+
+// ParseMigrationsFS parses SQL migration files of migrations directory fsys, e.g. embed.FS.
+// migrationsDir is used in file paths of migrations only.
+func ParseMigrationsFS(fsys fs.FS, migrationsDir string, dialect splitter.Dialect) ([]migrator.Migration, error) {
+	dirEntries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return nil, err
 	}
 
+	// Go-миграции регистрируются в коде, поэтому читаем только SQL-файлы.
+	files := make([]fs.DirEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || filepath.Ext(dirEntry.Name()) != sqlMigrationFileExt {
+			continue
+		}
+		files = append(files, dirEntry)
+	}
+
 	migrations := make([]migrator.Migration, len(files))
 	parsingErrors := map[string]error{}
 	wg := &sync.WaitGroup{}
@@ -29,44 +55,16 @@ func ParseMigrations(migrationsDir string) ([]migrator.Migration, error) {
 		go func(i int) {
 			defer wg.Done()
 
-			filePath := filepath.Join(migrationsDir, file.Name())
-			fileInfo, err := os.Stat(filePath)
-			if err != nil {
-				parsingErrors[file.Name()] = err
-				return
-			}
-
-			upStmt, downStmt, err := parseUpDownStatements(filePath)
-			if err != nil {
-				parsingErrors[file.Name()] = err
-				return
-			}
+			migration, err := ParseMigrationFS(fsys, migrationsDir, file.Name(), dialect)
 
 			mu.Lock()
 			defer mu.Unlock()
 
-			migrations[i] = migrator.Migration{
-				FilePath: filePath,
-				Name:     filepath.Base(file.Name()),
-				Status:   migrator.MigrationStatusUnknown,
-				CreateDT: fileInfo.ModTime(),
-				UpHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
-					_, err := tx.ExecContext(ctx, upStmt)
-					if err != nil {
-						return err
-					}
-
-					return nil
-				},
-				DownHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
-					_, err := tx.ExecContext(ctx, downStmt)
-					if err != nil {
-						return err
-					}
-
-					return nil
-				},
+			if err != nil {
+				parsingErrors[file.Name()] = err
+				return
 			}
+			migrations[i] = migration
 		}(i)
 	}
 	wg.Wait()
@@ -78,57 +76,173 @@ func ParseMigrations(migrationsDir string) ([]migrator.Migration, error) {
 	return migrations, nil
 }
 
-func GetMigrationFileHandlers(
+// Checksum returns checksum of migration up and down statements.
+func Checksum(upStmt, downStmt string) string {
+	hash := sha256.New()
+	hash.Write([]byte(upStmt))
+	hash.Write([]byte{0})
+	hash.Write([]byte(downStmt))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ParseMigration parses single SQL migration file.
+func ParseMigration(
 	migrationsDir,
 	migrationFileName string,
-) (
-	migrator.MigrationHandlerContext,
-	migrator.MigrationHandlerContext,
-	error,
-) {
+	dialect splitter.Dialect,
+) (migrator.Migration, error) {
+	return ParseMigrationFS(os.DirFS(migrationsDir), migrationsDir, migrationFileName, dialect)
+}
+
+// ParseMigrationFS parses single SQL migration file of migrations directory fsys.
+func ParseMigrationFS(
+	fsys fs.FS,
+	migrationsDir,
+	migrationFileName string,
+	dialect splitter.Dialect,
+) (migrator.Migration, error) {
 	filePath := filepath.Join(migrationsDir, migrationFileName)
-	upStmt, downStmt, err := parseUpDownStatements(filePath)
+	fileInfo, err := fs.Stat(fsys, migrationFileName)
 	if err != nil {
-		return nil, nil, err
+		return migrator.Migration{}, err
 	}
 
-	upHandler := func(ctx context.Context, tx *sqlx.Tx) error {
-		_, err := tx.ExecContext(ctx, upStmt)
-		if err != nil {
-			return err
-		}
+	migrationFile, err := parseMigrationFile(fsys, migrationFileName, dialect)
+	if err != nil {
+		return migrator.Migration{}, err
+	}
+
+	migration := migrator.Migration{
+		FilePath:       filePath,
+		Name:           filepath.Base(migrationFileName),
+		Status:         migrator.MigrationStatusUnknown,
+		CreateDT:       fileInfo.ModTime(),
+		Checksum:       Checksum(migrationFile.upStmt, migrationFile.downStmt),
+		NoTransaction:  migrationFile.noTransaction,
+		UpStatements:   statementTexts(migrationFile.upStatements),
+		DownStatements: statementTexts(migrationFile.downStatements),
+	}
 
-		return nil
+	if migrationFile.noTransaction {
+		migration.UpHandlerNoTxContext = newStatementsNoTxHandler(filePath, migrationFile.upStatements)
+		migration.DownHandlerNoTxContext = newStatementsNoTxHandler(filePath, migrationFile.downStatements)
+	} else {
+		migration.UpHandlerContext = newStatementsHandler(filePath, migrationFile.upStatements)
+		migration.DownHandlerContext = newStatementsHandler(filePath, migrationFile.downStatements)
 	}
 
-	downHandler := func(ctx context.Context, tx *sqlx.Tx) error {
-		_, err := tx.ExecContext(ctx, downStmt)
+	return migration, nil
+}
+
+func statementTexts(statements []splitter.Statement) []string {
+	texts := make([]string, len(statements))
+	for i := range statements {
+		texts[i] = statements[i].Text
+	}
+
+	return texts
+}
+
+func newStatementsHandler(filePath string, statements []splitter.Statement) migrator.MigrationHandlerContext {
+	return func(ctx context.Context, tx *sqlx.Tx) error {
+		return execStatements(ctx, tx, filePath, statements)
+	}
+}
+
+func newStatementsNoTxHandler(filePath string, statements []splitter.Statement) migrator.MigrationHandlerNoTxContext {
+	return func(ctx context.Context, conn *sqlx.Conn) error {
+		return execStatements(ctx, conn, filePath, statements)
+	}
+}
+
+// execStatements executes statements one by one.
+// Failed statement is reported as *migrator.StatementError.
+func execStatements(
+	ctx context.Context,
+	execer sqlx.ExecerContext,
+	filePath string,
+	statements []splitter.Statement,
+) error {
+	for i, statement := range statements {
+		_, err := execer.ExecContext(ctx, statement.Text)
 		if err != nil {
-			return err
+			return &migrator.StatementError{
+				FilePath:  filePath,
+				Line:      statement.Line,
+				Index:     i + 1,
+				Statement: statement.Text,
+				Err:       err,
+			}
 		}
-
-		return nil
 	}
 
-	return upHandler, downHandler, nil
+	return nil
 }
 
-func parseUpDownStatements(filePath string) (upStmt, downStmt string, err error) {
-	b, err := os.ReadFile(filePath)
+var (
+	ErrMissingMarkers = errors.New(`migration file must contain "` + upMarker + `" and "` + downMarker + `" markers`)
+
+	upDownStatementsRe = regexp.MustCompile(
+		`(?ms)` + upMarker + `\n(?P<up_stmt>.*)\n` + downMarker + `(?:\n(?P<down_stmt>.*))?`,
+	)
+)
+
+const (
+	upMarker   = "-- migration: up"
+	downMarker = "-- migration: down"
+	// noTransactionDirective placed before up marker makes migration run outside of transaction.
+	noTransactionDirective = "-- migration: no-transaction"
+)
+
+// migrationFile is a parsed SQL migration file.
+type migrationFile struct {
+	noTransaction  bool
+	upStmt         string
+	downStmt       string
+	upStatements   []splitter.Statement
+	downStatements []splitter.Statement
+}
+
+func parseMigrationFile(fsys fs.FS, fileName string, dialect splitter.Dialect) (migrationFile, error) {
+	var parsed migrationFile
+
+	b, err := fs.ReadFile(fsys, fileName)
 	if err != nil {
-		return
+		return parsed, err
+	}
+
+	match := upDownStatementsRe.FindSubmatchIndex(b)
+	if match == nil {
+		return parsed, ErrMissingMarkers
 	}
 
-	re := regexp.MustCompile(`(?ms)-- migration: up\n(?P<up_stmt>.*)\n-- migration: down\n(?P<down_stmt>.*)`)
-	match := re.FindSubmatch(b)
+	for _, line := range strings.Split(string(b[:match[0]]), "\n") {
+		if strings.TrimSpace(line) == noTransactionDirective {
+			parsed.noTransaction = true
+		}
+	}
+
+	for i, name := range upDownStatementsRe.SubexpNames() {
+		start, end := match[2*i], match[2*i+1]
+		if name == "" || start == -1 {
+			continue
+		}
+
+		// Номер строки, с которой начинается секция, нужен для точного указания места ошибки.
+		line := 1 + bytes.Count(b[:start], []byte("\n"))
+		stmt := string(b[start:end])
+		statements, err := splitter.Split(stmt, line, dialect)
+		if err != nil {
+			return parsed, fmt.Errorf("%s section: %w", strings.TrimSuffix(name, "_stmt"), err)
+		}
 
-	for i, name := range re.SubexpNames() {
 		if name == "up_stmt" {
-			upStmt = string(match[i])
+			parsed.upStmt, parsed.upStatements = stmt, statements
 		} else if name == "down_stmt" {
-			downStmt = string(match[i])
+			parsed.downStmt, parsed.downStatements = stmt, statements
 		}
 	}
 
-	return
+	return parsed, nil
 }
@@ -2,15 +2,19 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"testing/fstest"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
+	"github.com/wursta/gomigrator/internal/splitter"
+	migrator "github.com/wursta/gomigrator/pkg/migrator"
 )
 
 func TestParseMigrationsSuccess(t *testing.T) {
-	migrations, err := ParseMigrations("./test/good_migrations")
+	migrations, err := ParseMigrations("./test/good_migrations", splitter.DialectPostgreSQL)
 	require.Nil(t, err)
 	require.Len(t, migrations, 4)
 
@@ -63,6 +67,177 @@ func TestParseMigrationsSuccess(t *testing.T) {
 	}
 }
 
+func TestChecksum(t *testing.T) {
+	checksum := Checksum("CREATE TABLE foo();", "DROP TABLE foo;")
+	require.Len(t, checksum, 64)
+	require.Equal(t, checksum, Checksum("CREATE TABLE foo();", "DROP TABLE foo;"))
+	require.NotEqual(t, checksum, Checksum("CREATE TABLE foo(id int);", "DROP TABLE foo;"))
+	require.NotEqual(t, checksum, Checksum("CREATE TABLE foo();DROP TABLE foo;", ""))
+
+	migrations, err := ParseMigrations("./test/mixed_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.Equal(t, Checksum("CREATE TABLE public.foo(\n    id SERIAL    \n);", "DROP TABLE public.foo;"), migrations[0].Checksum)
+}
+
+func TestParseMigrationsSkipNonSQLFiles(t *testing.T) {
+	migrations, err := ParseMigrations("./test/mixed_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.Equal(t, "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", migrations[0].Name)
+}
+
+func TestValidateMigrations(t *testing.T) {
+	validationErrors, err := ValidateMigrations("./test/good_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+
+	validationErrors, err = ValidateMigrations("./test/bad_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, validationErrors, 6)
+
+	expectedErrors := []struct {
+		fileName string
+		err      error
+	}{
+		{"2024_07_01T18_04_42__create_foo_table__wyvmi.sql", ErrDuplicateTimestamp},
+		{"2024_07_02T10_00_00__create_bar_table__asdfg.sql", ErrMissingMarkers},
+		{"2024_07_03T10_00_00__empty_migration__zxcvb.sql", ErrEmptyUpSection},
+		{"2024_07_04T10_00_00__unterminated_string__mnbvc.sql", splitter.ErrUnterminated},
+		{"2024_13_01T10_00_00__wrong_month__poiuy.sql", nil},
+		{"create_qux_table.sql", ErrInvalidFileName},
+	}
+	for i, expected := range expectedErrors {
+		require.Equal(t, expected.fileName, validationErrors[i].FileName)
+		if expected.err != nil {
+			require.ErrorIs(t, validationErrors[i], expected.err)
+		}
+	}
+}
+
+func TestParseMigrationsMissingMarkers(t *testing.T) {
+	_, err := ParseMigration(
+		"./test/bad_migrations",
+		"2024_07_02T10_00_00__create_bar_table__asdfg.sql",
+		splitter.DialectPostgreSQL,
+	)
+	require.ErrorIs(t, err, ErrMissingMarkers)
+}
+
+func TestParseMigrationsStatements(t *testing.T) {
+	migrations, err := ParseMigrations("./test/multi_statement_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE public.foo(
+    id SERIAL,
+    name text DEFAULT 'a;b'
+);`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO public.foo (name) VALUES ('foo');").WillReturnError(errors.New("insert error"))
+
+	tx, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	err = migrations[0].UpHandlerContext(context.Background(), tx)
+
+	var statementErr *migrator.StatementError
+	require.ErrorAs(t, err, &statementErr)
+	require.Equal(t, migrations[0].FilePath, statementErr.FilePath)
+	require.Equal(t, 8, statementErr.Line)
+	require.Equal(t, 2, statementErr.Index)
+	require.Equal(t, "INSERT INTO public.foo (name) VALUES ('foo');", statementErr.Statement)
+	require.EqualError(t, err, migrations[0].FilePath+":8: statement #2: insert error")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseMigrationsUnterminatedString(t *testing.T) {
+	_, err := ParseMigration(
+		"./test/bad_migrations",
+		"2024_07_04T10_00_00__unterminated_string__mnbvc.sql",
+		splitter.DialectPostgreSQL,
+	)
+	require.ErrorIs(t, err, splitter.ErrUnterminated)
+	require.EqualError(t, err, "up section: unterminated string literal at line 2")
+}
+
+func TestParseMigrationsFS(t *testing.T) {
+	migrationsFS := fstest.MapFS{
+		"2024_07_01T18_04_42__create_foo_table__wyvmi.sql": {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+		"2024_07_02T10_00_00__backfill_foo__abcde.go": {Data: []byte("package migrations")},
+	}
+
+	migrations, err := ParseMigrationsFS(migrationsFS, "migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.Equal(t, "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", migrations[0].Name)
+	require.Equal(t, "migrations/2024_07_01T18_04_42__create_foo_table__wyvmi.sql", migrations[0].FilePath)
+	require.NotEmpty(t, migrations[0].Checksum)
+
+	validationErrors, err := ValidateMigrationsFS(migrationsFS, splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Empty(t, validationErrors)
+}
+
+func TestParseMigrationsNoTransaction(t *testing.T) {
+	migrations, err := ParseMigrations("./test/no_transaction_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Len(t, migrations, 1)
+	require.True(t, migrations[0].NoTransaction)
+	require.Nil(t, migrations[0].UpHandlerContext)
+	require.Nil(t, migrations[0].DownHandlerContext)
+
+	migrations, err = ParseMigrations("./test/good_migrations", splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.False(t, migrations[0].NoTransaction)
+
+	db, mock, err := getDBMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migration, err := ParseMigration(
+		"./test/no_transaction_migrations",
+		"2024_07_05T10_00_00__create_foo_name_index__qwert.sql",
+		splitter.DialectPostgreSQL,
+	)
+	require.Nil(t, err)
+	require.Equal(t, []string{"CREATE INDEX CONCURRENTLY idx_foo_name ON public.foo (name);"}, migration.UpStatements)
+	require.Equal(t, []string{"DROP INDEX CONCURRENTLY idx_foo_name;"}, migration.DownStatements)
+
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY idx_foo_name ON public.foo (name);").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DROP INDEX CONCURRENTLY idx_foo_name;").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	require.Nil(t, migration.UpHandlerNoTxContext(ctx, conn))
+	require.Nil(t, migration.DownHandlerNoTxContext(ctx, conn))
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
 func getDBMock() (*sqlx.DB, sqlmock.Sqlmock, error) {
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
 	if err != nil {
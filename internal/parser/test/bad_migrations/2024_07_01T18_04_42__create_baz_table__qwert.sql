@@ -0,0 +1,4 @@
+-- migration: up
+CREATE TABLE public.baz(id SERIAL);
+-- migration: down
+DROP TABLE public.baz;
@@ -0,0 +1,6 @@
+-- migration: up
+CREATE TABLE public.foo(
+    id SERIAL    
+);
+-- migration: down
+DROP TABLE public.foo;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE TABLE public.bar(id SERIAL);
@@ -0,0 +1,3 @@
+-- migration: up
+
+-- migration: down
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- migration: up
+INSERT INTO public.foo (name) VALUES ('foo);
+-- migration: down
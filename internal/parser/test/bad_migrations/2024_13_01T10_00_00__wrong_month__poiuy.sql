@@ -0,0 +1,3 @@
+-- migration: up
+SELECT 1;
+-- migration: down
@@ -0,0 +1,4 @@
+-- migration: up
+CREATE TABLE public.qux(id SERIAL);
+-- migration: down
+DROP TABLE public.qux;
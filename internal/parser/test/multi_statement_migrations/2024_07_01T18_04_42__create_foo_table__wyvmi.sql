@@ -0,0 +1,10 @@
+-- migration: up
+CREATE TABLE public.foo(
+    id SERIAL,
+    name text DEFAULT 'a;b'
+);
+
+-- comment;
+INSERT INTO public.foo (name) VALUES ('foo');
+-- migration: down
+DROP TABLE public.foo;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- migration: no-transaction
+-- migration: up
+CREATE INDEX CONCURRENTLY idx_foo_name ON public.foo (name);
+-- migration: down
+DROP INDEX CONCURRENTLY idx_foo_name;
\ No newline at end of file
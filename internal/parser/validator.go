@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/wursta/gomigrator/internal/splitter"
+	"github.com/wursta/gomigrator/internal/utils"
+)
+
+var (
+	ErrInvalidFileName    = errors.New("file name must be in format <datetime>__<name>__<suffix>.sql")
+	ErrEmptyUpSection     = errors.New("up section of migration is empty")
+	ErrDuplicateTimestamp = errors.New("duplicate migration datetime")
+
+	migrationFileNameRe = regexp.MustCompile(`^\d{4}_\d{2}_\d{2}T\d{2}_\d{2}_\d{2}__.+__[a-zA-Z]{5}\.(sql|go)$`)
+)
+
+// ValidationError describes a problem of migration file found by ValidateMigrations.
+type ValidationError struct {
+	FileName string
+	Err      error
+}
+
+func (e ValidationError) Error() string {
+	return e.FileName + ": " + e.Err.Error()
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateMigrations checks migration files without applying them:
+// file names, up and down markers, non-empty up sections and uniqueness of migration datetimes.
+// Go migration files are checked by name only.
+func ValidateMigrations(migrationsDir string, dialect splitter.Dialect) ([]ValidationError, error) {
+	return ValidateMigrationsFS(os.DirFS(migrationsDir), dialect)
+}
+
+// ValidateMigrationsFS checks migration files of migrations directory fsys, e.g. embed.FS.
+func ValidateMigrationsFS(fsys fs.FS, dialect splitter.Dialect) ([]ValidationError, error) {
+	dirEntries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	validationErrors := []ValidationError{}
+	fileNamesByDateTime := map[string]string{}
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		fileName := dirEntry.Name()
+
+		if !migrationFileNameRe.MatchString(fileName) {
+			validationErrors = append(validationErrors, ValidationError{FileName: fileName, Err: ErrInvalidFileName})
+			continue
+		}
+
+		if _, err := utils.ParseMigrationFileDateTime(fileName); err != nil {
+			validationErrors = append(validationErrors, ValidationError{FileName: fileName, Err: err})
+			continue
+		}
+
+		dateTime := fileName[:len(utils.MigrationFileDateTime)]
+		if duplicate, ok := fileNamesByDateTime[dateTime]; ok {
+			validationErrors = append(validationErrors, ValidationError{
+				FileName: fileName,
+				Err:      fmt.Errorf("%w: same as %s", ErrDuplicateTimestamp, duplicate),
+			})
+		} else {
+			fileNamesByDateTime[dateTime] = fileName
+		}
+
+		if filepath.Ext(fileName) != sqlMigrationFileExt {
+			continue
+		}
+
+		migrationFile, err := parseMigrationFile(fsys, fileName, dialect)
+		if err != nil {
+			validationErrors = append(validationErrors, ValidationError{FileName: fileName, Err: err})
+			continue
+		}
+
+		if len(migrationFile.upStatements) == 0 {
+			validationErrors = append(validationErrors, ValidationError{FileName: fileName, Err: ErrEmptyUpSection})
+		}
+	}
+
+	return validationErrors, nil
+}
@@ -0,0 +1,370 @@
+package splitter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnterminated = errors.New("unterminated")
+
+// Dialect is SQL dialect of migration scripts.
+type Dialect int
+
+const (
+	DialectPostgreSQL Dialect = iota
+	DialectMySQL
+	DialectSQLite
+)
+
+// Statement is a single SQL statement of migration.
+type Statement struct {
+	Text string
+	// Line is a number of the line the statement starts at.
+	Line int
+}
+
+// Split splits SQL script into statements separated by semicolons.
+// Semicolons inside string literals, quoted identifiers, comments and bodies of routines don't separate statements:
+// dollar-quoted bodies and BEGIN ATOMIC ... END blocks for PostgreSQL, BEGIN ... END compound statements
+// for MySQL and BEGIN ... END bodies of triggers for SQLite.
+// Empty statements and statements consisting of comments only are skipped.
+// firstLine is a number of the line sql starts at, it is used for statement lines and errors.
+func Split(sql string, firstLine int, dialect Dialect) ([]Statement, error) {
+	s := &scanner{sql: sql, line: firstLine, firstLine: firstLine, dialect: dialect}
+	return s.split()
+}
+
+type scanner struct {
+	sql       string
+	pos       int
+	line      int
+	firstLine int
+	dialect   Dialect
+
+	statements []Statement
+	stmtStart  int
+	stmtLine   int
+
+	lastWord   string
+	blockDepth int
+	blockPos   int
+	caseDepth  int
+}
+
+func (s *scanner) split() ([]Statement, error) {
+	s.stmtStart = -1
+
+	for s.pos < len(s.sql) {
+		c := s.sql[s.pos]
+
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+			continue
+		case isSpace(c):
+			s.pos++
+			continue
+		case s.isLineComment():
+			s.skipLineComment()
+			continue
+		case c == '/' && s.peek(1) == '*':
+			if err := s.skipBlockComment(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if s.stmtStart == -1 {
+			s.stmtStart = s.pos
+			s.stmtLine = s.line
+		}
+
+		var err error
+		switch {
+		case c == '\'':
+			// В MySQL обратный слэш экранирует символы в строках по умолчанию.
+			err = s.skipQuoted(c, "string literal", s.dialect == DialectMySQL)
+			s.lastWord = ""
+		case c == '"' && s.dialect == DialectMySQL:
+			err = s.skipQuoted(c, "string literal", true)
+			s.lastWord = ""
+		case c == '"', c == '`' && s.dialect != DialectPostgreSQL:
+			err = s.skipQuoted(c, "quoted identifier", false)
+			s.lastWord = ""
+		case c == '[' && s.dialect == DialectSQLite:
+			err = s.skipBracketQuoted()
+			s.lastWord = ""
+		case c == '$' && s.dialect == DialectPostgreSQL && s.dollarTag() != "":
+			err = s.skipDollarQuoted(s.dollarTag())
+			s.lastWord = ""
+		case c == ';':
+			s.pos++
+			if s.blockDepth == 0 {
+				s.addStatement(s.pos)
+			}
+			s.lastWord = ""
+		case isIdentStart(c):
+			err = s.scanWord()
+		default:
+			s.pos++
+			s.lastWord = ""
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.blockDepth != 0 {
+		block := "BEGIN ... END"
+		if s.dialect == DialectPostgreSQL {
+			block = "BEGIN ATOMIC"
+		}
+		return nil, fmt.Errorf("%w %s block at line %d", ErrUnterminated, block, s.lineAt(s.blockPos))
+	}
+
+	if s.stmtStart != -1 {
+		s.addStatement(len(s.sql))
+	}
+
+	return s.statements, nil
+}
+
+func (s *scanner) addStatement(end int) {
+	text := strings.TrimSpace(s.sql[s.stmtStart:end])
+	s.stmtStart = -1
+	if text == ";" {
+		return
+	}
+
+	s.statements = append(s.statements, Statement{
+		Text: text,
+		Line: s.stmtLine,
+	})
+}
+
+func (s *scanner) peek(offset int) byte {
+	if s.pos+offset >= len(s.sql) {
+		return 0
+	}
+
+	return s.sql[s.pos+offset]
+}
+
+// advance moves position to end counting lines.
+func (s *scanner) advance(end int) {
+	s.line += strings.Count(s.sql[s.pos:end], "\n")
+	s.pos = end
+}
+
+func (s *scanner) lineAt(pos int) int {
+	return s.firstLine + strings.Count(s.sql[:pos], "\n")
+}
+
+// isLineComment checks if line comment starts at current position.
+// MySQL requires whitespace after "--" and also supports "#" comments.
+func (s *scanner) isLineComment() bool {
+	if s.dialect == DialectMySQL {
+		next := s.peek(2)
+		return s.sql[s.pos] == '#' ||
+			s.sql[s.pos] == '-' && s.peek(1) == '-' && (next == 0 || next == '\n' || isSpace(next))
+	}
+
+	return s.sql[s.pos] == '-' && s.peek(1) == '-'
+}
+
+func (s *scanner) skipLineComment() {
+	end := strings.IndexByte(s.sql[s.pos:], '\n')
+	if end == -1 {
+		s.pos = len(s.sql)
+		return
+	}
+
+	s.pos += end
+}
+
+// skipBlockComment skips /* */ comment, which can be nested in PostgreSQL.
+func (s *scanner) skipBlockComment() error {
+	start := s.pos
+	depth := 0
+	for i := s.pos; i < len(s.sql)-1; i++ {
+		switch {
+		case s.sql[i] == '/' && s.sql[i+1] == '*' && (depth == 0 || s.dialect == DialectPostgreSQL):
+			depth++
+			i++
+		case s.sql[i] == '*' && s.sql[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				s.advance(i + 1)
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w comment at line %d", ErrUnterminated, s.lineAt(start))
+}
+
+// skipQuoted skips string literal or quoted identifier. Quote inside is escaped by doubling,
+// backslash escapes are allowed in PostgreSQL E'...' strings and in MySQL strings.
+func (s *scanner) skipQuoted(quote byte, name string, backslashEscapes bool) error {
+	start := s.pos
+	for i := s.pos + 1; i < len(s.sql); i++ {
+		switch s.sql[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(s.sql) && s.sql[i+1] == quote {
+				i++
+				continue
+			}
+			s.advance(i + 1)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w %s at line %d", ErrUnterminated, name, s.lineAt(start))
+}
+
+// skipBracketQuoted skips SQLite [identifier].
+func (s *scanner) skipBracketQuoted() error {
+	end := strings.IndexByte(s.sql[s.pos:], ']')
+	if end == -1 {
+		return fmt.Errorf("%w quoted identifier at line %d", ErrUnterminated, s.lineAt(s.pos))
+	}
+
+	s.advance(s.pos + end + 1)
+	return nil
+}
+
+// dollarTag returns dollar quote tag like $$ or $body$ starting at current position.
+// Positional parameters like $1 are not tags.
+func (s *scanner) dollarTag() string {
+	for i := s.pos + 1; i < len(s.sql); i++ {
+		c := s.sql[i]
+		switch {
+		case c == '$':
+			return s.sql[s.pos : i+1]
+		case isIdentStart(c), i > s.pos+1 && c >= '0' && c <= '9':
+			continue
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+func (s *scanner) skipDollarQuoted(tag string) error {
+	start := s.pos
+	end := strings.Index(s.sql[s.pos+len(tag):], tag)
+	if end == -1 {
+		return fmt.Errorf("%w dollar-quoted string %s at line %d", ErrUnterminated, tag, s.lineAt(start))
+	}
+
+	s.advance(s.pos + len(tag) + end + len(tag))
+	return nil
+}
+
+// scanWord reads keyword or identifier and tracks blocks with bodies of routines.
+func (s *scanner) scanWord() error {
+	start := s.pos
+	for s.pos < len(s.sql) && isIdentPart(s.sql[s.pos]) {
+		s.pos++
+	}
+	word := strings.ToUpper(s.sql[start:s.pos])
+
+	if s.dialect != DialectPostgreSQL {
+		s.trackBlock(word, start)
+		return nil
+	}
+
+	// E'...' - строка с escape-последовательностями.
+	if word == "E" && s.pos < len(s.sql) && s.sql[s.pos] == '\'' {
+		s.lastWord = ""
+		return s.skipQuoted('\'', "string literal", true)
+	}
+
+	switch {
+	case s.blockDepth != 0 && word == "CASE":
+		s.caseDepth++
+	case s.blockDepth != 0 && word == "END":
+		if s.caseDepth > 0 {
+			s.caseDepth--
+		} else {
+			s.blockDepth = 0
+		}
+	case s.blockDepth == 0 && word == "ATOMIC" && s.lastWord == "BEGIN":
+		s.blockDepth = 1
+		s.blockPos = start
+		s.caseDepth = 0
+	}
+	s.lastWord = word
+
+	return nil
+}
+
+// trackBlock tracks BEGIN ... END compound statements of MySQL routines and bodies of triggers.
+// BEGIN at the start of statement begins transaction, not a block, except MariaDB BEGIN NOT ATOMIC.
+// END IF, END LOOP, END WHILE and END REPEAT close MySQL control flow statements, not blocks.
+func (s *scanner) trackBlock(word string, start int) {
+	switch {
+	case word == "BEGIN" && (start != s.stmtStart || s.isNextWord("NOT")):
+		if s.blockDepth == 0 {
+			s.blockPos = start
+			s.caseDepth = 0
+		}
+		s.blockDepth++
+	case s.blockDepth != 0 && word == "CASE":
+		s.caseDepth++
+	case s.blockDepth != 0 && word == "END":
+		next, end := s.nextWord()
+		switch {
+		case next == "IF" || next == "LOOP" || next == "WHILE" || next == "REPEAT":
+			s.advance(end)
+		case next == "CASE":
+			s.advance(end)
+			s.caseDepth--
+		case s.caseDepth > 0:
+			s.caseDepth--
+		default:
+			s.blockDepth--
+		}
+	}
+	s.lastWord = word
+}
+
+// nextWord returns next word after whitespaces and position of its end.
+func (s *scanner) nextWord() (string, int) {
+	start := s.pos
+	for start < len(s.sql) && (isSpace(s.sql[start]) || s.sql[start] == '\n') {
+		start++
+	}
+
+	end := start
+	for end < len(s.sql) && isIdentPart(s.sql[end]) {
+		end++
+	}
+
+	return strings.ToUpper(s.sql[start:end]), end
+}
+
+func (s *scanner) isNextWord(word string) bool {
+	next, _ := s.nextWord()
+	return next == word
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '$'
+}
@@ -0,0 +1,294 @@
+package splitter_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/gomigrator/internal/splitter"
+)
+
+func TestSplit(t *testing.T) {
+	tests := map[string]struct {
+		sql      string
+		expected []splitter.Statement
+	}{
+		"empty": {
+			sql:      "\n  \n",
+			expected: nil,
+		},
+		"comments only": {
+			sql:      "-- comment;\n/* another; comment */\n",
+			expected: nil,
+		},
+		"single statement without semicolon": {
+			sql: "\nSELECT 1\n",
+			expected: []splitter.Statement{
+				{Text: "SELECT 1", Line: 2},
+			},
+		},
+		"several statements": {
+			sql: "CREATE TABLE foo (id int);\n\n-- comment\nINSERT INTO foo VALUES (1);\nINSERT INTO foo VALUES (2);",
+			expected: []splitter.Statement{
+				{Text: "CREATE TABLE foo (id int);", Line: 1},
+				{Text: "INSERT INTO foo VALUES (1);", Line: 4},
+				{Text: "INSERT INTO foo VALUES (2);", Line: 5},
+			},
+		},
+		"empty statements": {
+			sql: ";;SELECT 1;;",
+			expected: []splitter.Statement{
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"string literals": {
+			sql: "INSERT INTO foo VALUES ('a;b', 'it''s;', E'\\';');\nSELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "INSERT INTO foo VALUES ('a;b', 'it''s;', E'\\';');", Line: 1},
+				{Text: "SELECT 1;", Line: 2},
+			},
+		},
+		"multiline string literal": {
+			sql: "INSERT INTO foo VALUES ('a;\nb');\nSELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "INSERT INTO foo VALUES ('a;\nb');", Line: 1},
+				{Text: "SELECT 1;", Line: 3},
+			},
+		},
+		"quoted identifiers": {
+			sql: `CREATE TABLE "foo;bar" ("a""b;" int);SELECT 1;`,
+			expected: []splitter.Statement{
+				{Text: `CREATE TABLE "foo;bar" ("a""b;" int);`, Line: 1},
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"comments inside statement": {
+			sql: "SELECT 1 -- comment;\n/* comment; /* nested; */ */ + 1;\nSELECT 2;",
+			expected: []splitter.Statement{
+				{Text: "SELECT 1 -- comment;\n/* comment; /* nested; */ */ + 1;", Line: 1},
+				{Text: "SELECT 2;", Line: 3},
+			},
+		},
+		"dollar quoted function body": {
+			sql: "CREATE FUNCTION foo() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;\n" +
+				"CREATE FUNCTION bar() RETURNS text AS $body$ SELECT '$$;' $body$ LANGUAGE sql;",
+			expected: []splitter.Statement{
+				{Text: "CREATE FUNCTION foo() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql;", Line: 1},
+				{Text: "CREATE FUNCTION bar() RETURNS text AS $body$ SELECT '$$;' $body$ LANGUAGE sql;", Line: 6},
+			},
+		},
+		"positional parameters": {
+			sql: "PREPARE foo AS SELECT $1, a$b FROM t;SELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "PREPARE foo AS SELECT $1, a$b FROM t;", Line: 1},
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"begin atomic": {
+			sql: "CREATE FUNCTION foo(a int) RETURNS int LANGUAGE sql\nBEGIN ATOMIC\n" +
+				"  SELECT CASE WHEN a > 0 THEN 1 ELSE 0 END;\n  SELECT 2;\nEND;\nSELECT 3;",
+			expected: []splitter.Statement{
+				{
+					Text: "CREATE FUNCTION foo(a int) RETURNS int LANGUAGE sql\nBEGIN ATOMIC\n" +
+						"  SELECT CASE WHEN a > 0 THEN 1 ELSE 0 END;\n  SELECT 2;\nEND;",
+					Line: 1,
+				},
+				{Text: "SELECT 3;", Line: 6},
+			},
+		},
+		"transaction begin": {
+			sql: "BEGIN;\nSELECT 1;\nEND;",
+			expected: []splitter.Statement{
+				{Text: "BEGIN;", Line: 1},
+				{Text: "SELECT 1;", Line: 2},
+				{Text: "END;", Line: 3},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", i), func(t *testing.T) {
+			testCase := tt
+
+			t.Parallel()
+
+			statements, err := splitter.Split(testCase.sql, 1, splitter.DialectPostgreSQL)
+			require.Nil(t, err)
+			require.Equal(t, testCase.expected, statements)
+		})
+	}
+}
+
+func TestSplitFirstLine(t *testing.T) {
+	statements, err := splitter.Split("\nSELECT 1;\nSELECT 2;", 10, splitter.DialectPostgreSQL)
+	require.Nil(t, err)
+	require.Equal(t, []splitter.Statement{
+		{Text: "SELECT 1;", Line: 11},
+		{Text: "SELECT 2;", Line: 12},
+	}, statements)
+}
+
+func TestSplitFailure(t *testing.T) {
+	tests := map[string]struct {
+		sql           string
+		expectedError string
+	}{
+		"string literal": {
+			sql:           "SELECT 1;\nSELECT 'foo;",
+			expectedError: "unterminated string literal at line 2",
+		},
+		"quoted identifier": {
+			sql:           `SELECT "foo;`,
+			expectedError: "unterminated quoted identifier at line 1",
+		},
+		"comment": {
+			sql:           "SELECT 1;\n\n/* comment /* nested */",
+			expectedError: "unterminated comment at line 3",
+		},
+		"dollar quoted string": {
+			sql:           "SELECT 1;\nDO $body$ BEGIN END; $$;",
+			expectedError: "unterminated dollar-quoted string $body$ at line 2",
+		},
+		"begin atomic": {
+			sql:           "CREATE FUNCTION foo() RETURNS int LANGUAGE sql\nBEGIN ATOMIC\nSELECT 1;",
+			expectedError: "unterminated BEGIN ATOMIC block at line 2",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", i), func(t *testing.T) {
+			testCase := tt
+
+			t.Parallel()
+
+			_, err := splitter.Split(testCase.sql, 1, splitter.DialectPostgreSQL)
+			require.ErrorIs(t, err, splitter.ErrUnterminated)
+			require.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestSplitMySQL(t *testing.T) {
+	tests := map[string]struct {
+		sql      string
+		expected []splitter.Statement
+	}{
+		"strings with backslash escapes": {
+			sql: "INSERT INTO foo VALUES ('it\\'s;', \"a\\\";b\");\nSELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "INSERT INTO foo VALUES ('it\\'s;', \"a\\\";b\");", Line: 1},
+				{Text: "SELECT 1;", Line: 2},
+			},
+		},
+		"quoted identifiers": {
+			sql: "CREATE TABLE `foo;bar` (`a``b;` int);SELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "CREATE TABLE `foo;bar` (`a``b;` int);", Line: 1},
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"comments": {
+			sql: "# comment;\nSELECT 1 -- comment;\n, 2--1;\n/* comment; */SELECT 3;",
+			expected: []splitter.Statement{
+				{Text: "SELECT 1 -- comment;\n, 2--1;", Line: 2},
+				{Text: "SELECT 3;", Line: 4},
+			},
+		},
+		"dollar is not a quote": {
+			sql: "SELECT $$;SELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "SELECT $$;", Line: 1},
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"transaction begin": {
+			sql: "BEGIN;\nSELECT 1;\nCOMMIT;",
+			expected: []splitter.Statement{
+				{Text: "BEGIN;", Line: 1},
+				{Text: "SELECT 1;", Line: 2},
+				{Text: "COMMIT;", Line: 3},
+			},
+		},
+		"compound statement": {
+			sql: "CREATE PROCEDURE foo()\nBEGIN\n  IF 1 THEN SELECT 1; END IF;\n" +
+				"  SELECT CASE WHEN 1 THEN 1 END;\n  CASE WHEN 1 THEN SELECT 1; END CASE;\n" +
+				"  lbl: BEGIN SELECT 2; END lbl;\nEND;\nSELECT 3;",
+			expected: []splitter.Statement{
+				{
+					Text: "CREATE PROCEDURE foo()\nBEGIN\n  IF 1 THEN SELECT 1; END IF;\n" +
+						"  SELECT CASE WHEN 1 THEN 1 END;\n  CASE WHEN 1 THEN SELECT 1; END CASE;\n" +
+						"  lbl: BEGIN SELECT 2; END lbl;\nEND;",
+					Line: 1,
+				},
+				{Text: "SELECT 3;", Line: 8},
+			},
+		},
+		"trigger": {
+			sql: "CREATE TRIGGER foo_bi BEFORE INSERT ON foo FOR EACH ROW\nBEGIN\n  SET NEW.a = 1;\nEND;",
+			expected: []splitter.Statement{
+				{Text: "CREATE TRIGGER foo_bi BEFORE INSERT ON foo FOR EACH ROW\nBEGIN\n  SET NEW.a = 1;\nEND;", Line: 1},
+			},
+		},
+		"anonymous block": {
+			sql: "BEGIN NOT ATOMIC\n  SELECT 1;\nEND;\nSELECT 2;",
+			expected: []splitter.Statement{
+				{Text: "BEGIN NOT ATOMIC\n  SELECT 1;\nEND;", Line: 1},
+				{Text: "SELECT 2;", Line: 4},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", i), func(t *testing.T) {
+			testCase := tt
+
+			t.Parallel()
+
+			statements, err := splitter.Split(testCase.sql, 1, splitter.DialectMySQL)
+			require.Nil(t, err)
+			require.Equal(t, testCase.expected, statements)
+		})
+	}
+}
+
+func TestSplitSQLite(t *testing.T) {
+	tests := map[string]struct {
+		sql      string
+		expected []splitter.Statement
+	}{
+		"quoted identifiers": {
+			sql: "CREATE TABLE [foo;bar] (`a;` int, \"b;\" int, c text DEFAULT 'it''s\\;');SELECT 1;",
+			expected: []splitter.Statement{
+				{Text: "CREATE TABLE [foo;bar] (`a;` int, \"b;\" int, c text DEFAULT 'it''s\\;');", Line: 1},
+				{Text: "SELECT 1;", Line: 1},
+			},
+		},
+		"trigger": {
+			sql: "BEGIN IMMEDIATE;\nCREATE TRIGGER foo_ai AFTER INSERT ON foo\nBEGIN\n" +
+				"  UPDATE foo SET b = CASE WHEN NEW.a > 0 THEN 1 ELSE 0 END WHERE id = NEW.id;\n" +
+				"  DELETE FROM bar;\nEND;\nCOMMIT;",
+			expected: []splitter.Statement{
+				{Text: "BEGIN IMMEDIATE;", Line: 1},
+				{
+					Text: "CREATE TRIGGER foo_ai AFTER INSERT ON foo\nBEGIN\n" +
+						"  UPDATE foo SET b = CASE WHEN NEW.a > 0 THEN 1 ELSE 0 END WHERE id = NEW.id;\n" +
+						"  DELETE FROM bar;\nEND;",
+					Line: 2,
+				},
+				{Text: "COMMIT;", Line: 7},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", i), func(t *testing.T) {
+			testCase := tt
+
+			t.Parallel()
+
+			statements, err := splitter.Split(testCase.sql, 1, splitter.DialectSQLite)
+			require.Nil(t, err)
+			require.Equal(t, testCase.expected, statements)
+		})
+	}
+}
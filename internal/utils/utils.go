@@ -32,6 +32,20 @@ func GetMigrationFileName(createDatetime time.Time, migrationName, suffix string
 	return createDatetime.Format(MigrationFileDateTime + "__" + migrationName + "__" + suffix)
 }
 
+// ParseMigrationFileDateTime returns creation datetime encoded at the beginning of migration file name.
+func ParseMigrationFileDateTime(migrationFileName string) (time.Time, error) {
+	if len(migrationFileName) < len(MigrationFileDateTime) {
+		return time.Time{}, fmt.Errorf("migration file name %q is too short", migrationFileName)
+	}
+
+	createDatetime, err := time.Parse(MigrationFileDateTime, migrationFileName[:len(MigrationFileDateTime)])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse migration file datetime: %w", err)
+	}
+
+	return createDatetime, nil
+}
+
 func CreateMigrationFile(
 	createDatetime time.Time,
 	dir,
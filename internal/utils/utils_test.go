@@ -53,6 +53,47 @@ func TestGetMigrationFileName(t *testing.T) {
 	}
 }
 
+func TestParseMigrationFileDateTime(t *testing.T) {
+	tests := map[string]struct {
+		migrationFileName string
+		expectedDatetime  time.Time
+		expectedErr       bool
+	}{
+		"sql file": {
+			migrationFileName: "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+			expectedDatetime:  time.Date(2024, 7, 5, 18, 51, 7, 0, time.UTC),
+		},
+		"go file": {
+			migrationFileName: "2024_07_09T20_34_36__backfill_foo__oypjB.go",
+			expectedDatetime:  time.Date(2024, 7, 9, 20, 34, 36, 0, time.UTC),
+		},
+		"too short": {
+			migrationFileName: "2024_07_09",
+			expectedErr:       true,
+		},
+		"wrong format": {
+			migrationFileName: "create_table_foo__hKnRd.sql",
+			expectedErr:       true,
+		},
+	}
+
+	for tName, tt := range tests {
+		t.Run(fmt.Sprintf("case %s", tName), func(t *testing.T) {
+			testCase := tt
+			t.Parallel()
+
+			createDatetime, err := ParseMigrationFileDateTime(testCase.migrationFileName)
+			if testCase.expectedErr {
+				require.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, testCase.expectedDatetime, createDatetime)
+		})
+	}
+}
+
 func TestCreateMigrationFile(t *testing.T) {
 	now := time.Now()
 	migrationNameStart := now.Format(MigrationFileDateTime)
@@ -2,51 +2,79 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
+	creatorgo "github.com/wursta/gomigrator/internal/creator/go"
 	creatorsql "github.com/wursta/gomigrator/internal/creator/sql"
-	migratorConstants "github.com/wursta/gomigrator/internal/migrator"
-	pgmigrator "github.com/wursta/gomigrator/internal/migrator/pg"
 	"github.com/wursta/gomigrator/internal/parser"
+	"github.com/wursta/gomigrator/internal/splitter"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
 )
 
 type MigrationFormat int
 
 const (
 	MigrationFormatSQL MigrationFormat = iota
-	// MigrationFormatGo.
+	MigrationFormatGo
 )
 
-type DBType int
+// DBType is a name of registered database driver.
+type DBType string
 
 const (
-	DBTypePotgreSQL DBType = iota
+	DBTypePotgreSQL DBType = "postgres"
+	DBTypeMySQL     DBType = "mysql"
+	DBTypeSQLite    DBType = "sqlite"
 )
 
 type MigratorApp struct {
-	MigrationsDir   string
+	MigrationsDir string
+	// MigrationsFS is a file system with migrations directory, e.g. embed.FS, so migrations can be shipped
+	// inside application binary. MigrationsDir is a path of migrations directory in MigrationsFS.
+	// If MigrationsFS is nil, migrations are read from MigrationsDir of OS file system.
+	MigrationsFS fs.FS
+	// DBType selects database driver, empty DBType is detected by DSN scheme.
 	DBType          DBType
 	DBConnectionDSN string
+	// HistorySchema and HistoryTable set migrations log table, so several services
+	// can keep independent migration histories in one database. Defaults are public.dbmigrations.
+	HistorySchema string
+	HistoryTable  string
+	// AllowChecksumMismatch makes Up and Status log checksum mismatches of applied migrations
+	// as warnings instead of returning an error.
+	AllowChecksumMismatch bool
+	// LockTimeout limits waiting for run lock held by another migrator in Up, Down and Redo,
+	// and for locks of migrations. Zero LockTimeout waits until ctx is done.
+	LockTimeout time.Duration
+	// NoWait makes Up, Down and Redo fail at once if run lock or lock of migration is held by another migrator.
+	NoWait bool
+	// Logger receives progress of applying migrations, nothing is logged if Logger is nil.
+	Logger *slog.Logger
+	// DB is an existing connection pool used instead of connecting with DBConnectionDSN.
+	// DBType must be set if DB of not PostgreSQL database is passed. Close doesn't close DB.
+	DB *sql.DB
+	// ToolVersion is a version of application recorded in migrations event log.
+	ToolVersion string
+
+	mu       sync.Mutex
+	migrator Migrator
 }
 
 type Creator interface {
 	Create(migrationName string) (*os.File, error)
 }
 
-type Migrator interface {
-	Connect(context.Context) error
-	Init(ctx context.Context) error
-	Up(ctx context.Context, migrations []migratorConstants.Migration) error
-	Down(ctx context.Context, migrations []migratorConstants.Migration) error
-	GetLastMigations(
-		ctx context.Context,
-		status migratorConstants.MigrationStatus,
-		count int,
-	) ([]migratorConstants.Migration, error)
-	GetMigations(ctx context.Context) ([]migratorConstants.Migration, error)
-	Close() error
-}
+// Migrator is a database driver, see migrator.Driver for the contract.
+type Migrator = migratorConstants.Driver
 
 func New(migrationsDir, dbConnectionDSN string, dbType DBType) *MigratorApp {
 	return &MigratorApp{
@@ -70,167 +98,322 @@ func (a *MigratorApp) CreateMigration(name string, format MigrationFormat) (*os.
 	return migrationFile, nil
 }
 
-func (a *MigratorApp) Up() error {
-	migrator, err := a.getMigrator()
+// Up applies new migrations. By default all new migrations are applied,
+// WithTarget and WithSteps options limit them.
+func (a *MigratorApp) Up(ctx context.Context, opts ...RunOption) (RunResult, error) {
+	result := RunResult{}
+
+	options, err := newRunOptions(opts)
 	if err != nil {
-		return err
+		return result, err
 	}
-
-	migrations, err := parser.ParseMigrations(a.MigrationsDir)
+	err = validateRunOptions(CommandUp, options, len(opts) != 0)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	ctx := context.Background()
-
-	err = migrator.Connect(ctx)
+	migrator, unlock, err := a.lock(ctx)
 	if err != nil {
-		return err
+		return result, err
 	}
-	defer migrator.Close()
+	defer unlock()
 
-	err = migrator.Init(ctx)
+	migrations, err := a.upMigrations(ctx, migrator, options)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	err = migrator.Up(ctx, migrations)
+	applied, err := migrator.Up(ctx, migrations)
+	result.add(migratorConstants.MigrationDirectionUp, applied)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	return nil
+	return result, nil
 }
 
-func (a *MigratorApp) Down() error {
-	migrator, err := a.getMigrator()
+// Down rollbacks the last applied migration. WithSteps, WithTarget and WithAll options
+// rollback several migrations in reverse order of applying. Rollback stops on the first failure.
+func (a *MigratorApp) Down(ctx context.Context, opts ...RunOption) (RunResult, error) {
+	result := RunResult{}
+
+	options, err := newRunOptions(opts)
+	if err != nil {
+		return result, err
+	}
+	err = validateRunOptions(CommandDown, options, len(opts) != 0)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	ctx := context.Background()
+	migrator, unlock, err := a.lock(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
 
-	err = migrator.Connect(ctx)
+	migrations, err := a.downMigrations(ctx, migrator, options)
 	if err != nil {
-		return err
+		return result, err
 	}
-	defer migrator.Close()
 
-	err = migrator.Init(ctx)
+	rolledBack, err := migrator.Down(ctx, migrations)
+	result.add(migratorConstants.MigrationDirectionDown, rolledBack)
 	if err != nil {
-		return err
+		// Миграции откатываются по порядку до первой ошибки.
+		result.addNotApplied(migratorConstants.MigrationDirectionDown, migrations[len(rolledBack):])
+		return result, err
 	}
 
-	migrations, err := migrator.GetLastMigations(ctx, migratorConstants.MigrationStatusMigrated, 1)
+	return result, nil
+}
+
+func (a *MigratorApp) Redo(ctx context.Context) (RunResult, error) {
+	result := RunResult{}
+
+	migrator, unlock, err := a.lock(ctx)
 	if err != nil {
-		return err
+		return result, err
 	}
+	defer unlock()
 
-	for i := range migrations {
-		upHandler, downHandler, err := parser.GetMigrationFileHandlers(a.MigrationsDir, migrations[i].Name)
-		if err != nil {
-			return err
-		}
-		migrations[i].UpHandlerContext = upHandler
-		migrations[i].DownHandlerContext = downHandler
+	migrations, err := a.downMigrations(ctx, migrator, runOptions{steps: 1})
+	if err != nil {
+		return result, err
 	}
 
-	err = migrator.Down(ctx, migrations)
+	rolledBack, err := migrator.Down(ctx, migrations)
+	result.add(migratorConstants.MigrationDirectionDown, rolledBack)
 	if err != nil {
-		return err
+		result.addNotApplied(migratorConstants.MigrationDirectionDown, migrations[len(rolledBack):])
+		return result, err
 	}
 
-	return nil
+	applied, err := migrator.Up(ctx, migrations)
+	result.add(migratorConstants.MigrationDirectionUp, applied)
+	if err != nil {
+		result.addNotApplied(migratorConstants.MigrationDirectionUp, migrations[len(applied):])
+		return result, err
+	}
+
+	return result, nil
 }
 
-func (a *MigratorApp) Redo() error {
-	migrator, err := a.getMigrator()
+// upMigrations returns migrations which should be applied by Up according to run options.
+// Migrations with migrated or migrating status are left out.
+func (a *MigratorApp) upMigrations(
+	ctx context.Context,
+	migrator Migrator,
+	options runOptions,
+) ([]migratorConstants.Migration, error) {
+	migrations, err := a.parseMigrations()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ctx := context.Background()
-
-	err = migrator.Connect(ctx)
+	dbMigrations, err := migrator.GetMigations(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer migrator.Close()
+	a.warnOrphaned(dbMigrations)
 
-	err = migrator.Init(ctx)
+	statuses := mergeMigrationStatuses(migrations, dbMigrations)
+	err = a.checkChecksums(statuses)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	migrations, err := migrator.GetLastMigations(ctx, migratorConstants.MigrationStatusMigrated, 1)
+	migrations, err = selectUpMigrations(migrations, dbMigrations, options)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	// Драйвер всё равно пропускает применённые миграции, но план должен содержать только новые.
+	applied := make(map[string]bool, len(statuses))
+	for i := range statuses {
+		applied[statuses[i].Name] = isApplied(statuses[i].Status)
+	}
+
+	pending := make([]migratorConstants.Migration, 0, len(migrations))
 	for i := range migrations {
-		upHandler, downHandler, err := parser.GetMigrationFileHandlers(a.MigrationsDir, migrations[i].Name)
-		if err != nil {
-			return err
+		if !applied[migrations[i].Name] {
+			pending = append(pending, migrations[i])
 		}
-		migrations[i].UpHandlerContext = upHandler
-		migrations[i].DownHandlerContext = downHandler
 	}
 
-	err = migrator.Down(ctx, migrations)
-	if err != nil {
-		return err
+	return pending, nil
+}
+
+// downMigrations returns applied migrations with handlers which should be rolled back by Down
+// according to run options, in order of rollback.
+func (a *MigratorApp) downMigrations(
+	ctx context.Context,
+	migrator Migrator,
+	options runOptions,
+) ([]migratorConstants.Migration, error) {
+	var migrations []migratorConstants.Migration
+	if options.target == "" && !options.all {
+		steps := options.steps
+		if steps == 0 {
+			steps = 1
+		}
+
+		var err error
+		migrations, err = migrator.GetLastMigations(ctx, migratorConstants.MigrationStatusMigrated, steps)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		dbMigrations, err := migrator.GetMigations(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		migrations, err = selectDownMigrations(dbMigrations, options)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = migrator.Up(ctx, migrations)
-	if err != nil {
-		return err
+	for i := range migrations {
+		err := a.setMigrationHandlers(&migrations[i])
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return migrations, nil
 }
 
-func (a *MigratorApp) Status() ([]migratorConstants.Migration, error) {
-	migrator, err := a.getMigrator()
+func (a *MigratorApp) Status(ctx context.Context) ([]migratorConstants.Migration, error) {
+	migrator, err := a.connect(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ctx := context.Background()
+	migrations, err := migrator.GetMigations(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	err = migrator.Connect(ctx)
+	fileMigrations, err := a.parseMigrations()
 	if err != nil {
 		return nil, err
 	}
-	defer migrator.Close()
 
-	err = migrator.Init(ctx)
+	statuses := mergeMigrationStatuses(fileMigrations, migrations)
+
+	err = a.checkChecksums(statuses)
 	if err != nil {
 		return nil, err
 	}
 
+	return statuses, nil
+}
+
+// Show returns status of migration matching target with details of its last applying.
+// Unlike Status, checksum mismatch isn't an error and is reported by ChecksumMismatch.
+func (a *MigratorApp) Show(ctx context.Context, target string) (migratorConstants.Migration, error) {
+	migrator, err := a.connect(ctx)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
 	migrations, err := migrator.GetMigations(ctx)
 	if err != nil {
-		return nil, err
+		return migratorConstants.Migration{}, err
 	}
 
-	return migrations, nil
+	fileMigrations, err := a.parseMigrations()
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
+	statuses := mergeMigrationStatuses(fileMigrations, migrations)
+	idx, err := findMigration(statuses, target)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
+	return statuses[idx], nil
 }
 
-func (a *MigratorApp) GetDBVersion() (uint, error) {
-	migrator, err := a.getMigrator()
+// mergeMigrationStatuses returns statuses of all migrations from migrations dir and database
+// ordered from the latest migration to the earliest one.
+// Migrations never applied to database get status "new".
+func mergeMigrationStatuses(
+	fileMigrations,
+	dbMigrations []migratorConstants.Migration,
+) []migratorConstants.Migration {
+	dbMigrationsByName := make(map[string]migratorConstants.Migration, len(dbMigrations))
+	for i := range dbMigrations {
+		dbMigrationsByName[dbMigrations[i].Name] = dbMigrations[i]
+	}
+
+	migrations := make([]migratorConstants.Migration, 0, len(fileMigrations)+len(dbMigrations))
+	for i := range fileMigrations {
+		dbMigration, ok := dbMigrationsByName[fileMigrations[i].Name]
+		if !ok {
+			migration := fileMigrations[i]
+			migration.Status = migratorConstants.MigrationStatusNew
+			migrations = append(migrations, migration)
+			continue
+		}
+
+		dbMigration.ChecksumMismatch = dbMigration.Status == migratorConstants.MigrationStatusMigrated &&
+			dbMigration.Checksum != "" &&
+			fileMigrations[i].Checksum != "" &&
+			dbMigration.Checksum != fileMigrations[i].Checksum
+
+		migrations = append(migrations, dbMigration)
+		delete(dbMigrationsByName, dbMigration.Name)
+	}
+
+	for i := range dbMigrations {
+		if _, ok := dbMigrationsByName[dbMigrations[i].Name]; !ok {
+			continue
+		}
+
+		migration := dbMigrations[i]
+		migration.FileMissing = true
+		migrations = append(migrations, migration)
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Name > migrations[j].Name
+	})
+
+	return migrations
+}
+
+// Validate checks migration files in migrations dir without connecting to database.
+// It returns problems found in migration files.
+func (a *MigratorApp) Validate() ([]error, error) {
+	dialect, err := a.getDialect()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	ctx := context.Background()
+	migrationsFS, err := a.migrationsFS()
+	if err != nil {
+		return nil, err
+	}
 
-	err = migrator.Connect(ctx)
+	validationErrors, err := parser.ValidateMigrationsFS(migrationsFS, dialect)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer migrator.Close()
 
-	err = migrator.Init(ctx)
+	errs := make([]error, len(validationErrors))
+	for i := range validationErrors {
+		errs[i] = validationErrors[i]
+	}
+
+	return errs, nil
+}
+
+func (a *MigratorApp) GetDBVersion(ctx context.Context) (uint, error) {
+	migrator, err := a.connect(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -247,11 +430,83 @@ func (a *MigratorApp) GetDBVersion() (uint, error) {
 	return *migrations[0].ID, nil
 }
 
+// parseMigrations returns SQL migrations from migrations dir merged with registered Go migrations.
+func (a *MigratorApp) parseMigrations() ([]migratorConstants.Migration, error) {
+	var sqlMigrations []migratorConstants.Migration
+	if a.MigrationsDir != "" || a.MigrationsFS != nil {
+		dialect, err := a.getDialect()
+		if err != nil {
+			return nil, err
+		}
+
+		migrationsFS, err := a.migrationsFS()
+		if err != nil {
+			return nil, err
+		}
+
+		sqlMigrations, err = parser.ParseMigrationsFS(migrationsFS, a.MigrationsDir, dialect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mergeMigrations(sqlMigrations, getGoMigrations())
+}
+
+// setMigrationHandlers sets handlers and checksum of migration loaded from database.
+func (a *MigratorApp) setMigrationHandlers(migration *migratorConstants.Migration) error {
+	source, ok := getGoMigration(migration.Name)
+	if !ok {
+		dialect, err := a.getDialect()
+		if err != nil {
+			return err
+		}
+
+		migrationsFS, err := a.migrationsFS()
+		if err != nil {
+			return err
+		}
+
+		source, err = parser.ParseMigrationFS(migrationsFS, a.MigrationsDir, migration.Name, dialect)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Checksum берётся из файла, иначе Redo записал бы в лог checksum файла до его изменения.
+	migration.Checksum = source.Checksum
+	migration.NoTransaction = source.NoTransaction
+	migration.UpHandlerContext = source.UpHandlerContext
+	migration.DownHandlerContext = source.DownHandlerContext
+	migration.UpHandlerNoTxContext = source.UpHandlerNoTxContext
+	migration.DownHandlerNoTxContext = source.DownHandlerNoTxContext
+	migration.UpStatements = source.UpStatements
+	migration.DownStatements = source.DownStatements
+
+	return nil
+}
+
+// migrationsFS returns file system rooted at migrations directory.
+func (a *MigratorApp) migrationsFS() (fs.FS, error) {
+	if a.MigrationsFS == nil {
+		dir := a.MigrationsDir
+		if dir == "" {
+			dir = "."
+		}
+		return os.DirFS(dir), nil
+	}
+
+	// Пути в fs.FS всегда разделяются слэшем и не могут начинаться с "./".
+	return fs.Sub(a.MigrationsFS, path.Clean(filepath.ToSlash(a.MigrationsDir)))
+}
+
 func (a *MigratorApp) getCreator(format MigrationFormat) (Creator, error) {
 	var creator Creator
 	switch format {
 	case MigrationFormatSQL:
 		creator = creatorsql.New(a.MigrationsDir)
+	case MigrationFormatGo:
+		creator = creatorgo.New(a.MigrationsDir)
 	default:
 		return nil, errors.New("creator for this format not realized")
 	}
@@ -260,13 +515,92 @@ func (a *MigratorApp) getCreator(format MigrationFormat) (Creator, error) {
 }
 
 func (a *MigratorApp) getMigrator() (Migrator, error) {
-	var migrator Migrator
-	switch a.DBType {
-	case DBTypePotgreSQL:
-		migrator = pgmigrator.New(a.DBConnectionDSN)
-	default:
-		return nil, errors.New("migrator for this database type not realized")
+	driver, err := a.getDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.New(a.DBConnectionDSN, DriverOptions{
+		HistorySchema: a.HistorySchema,
+		HistoryTable:  a.HistoryTable,
+		Logger:        a.logger(),
+		DB:            a.DB,
+		ToolVersion:   a.ToolVersion,
+		LockTimeout:   a.LockTimeout,
+		NoWait:        a.NoWait,
+	})
+}
+
+// connect returns connected driver with initialized migrations log.
+// Driver stays connected until Close, so subsequent calls reuse its connection.
+func (a *MigratorApp) connect(ctx context.Context) (Migrator, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.migrator != nil {
+		return a.migrator, nil
+	}
+
+	migrator, err := a.getMigrator()
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrator.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrator.Init(ctx)
+	if err != nil {
+		migrator.Close()
+		return nil, err
 	}
 
+	a.migrator = migrator
+
 	return migrator, nil
 }
+
+// Close closes database connection opened by application. Connection pool passed as DB is not closed.
+func (a *MigratorApp) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.migrator == nil {
+		return nil
+	}
+
+	err := a.migrator.Close()
+	a.migrator = nil
+
+	return err
+}
+
+func (a *MigratorApp) logger() *slog.Logger {
+	return migratorConstants.NewOptions(migratorConstants.WithLogger(a.Logger)).Logger
+}
+
+// getDialect returns SQL dialect of SQL migration files.
+func (a *MigratorApp) getDialect() (splitter.Dialect, error) {
+	driver, err := a.getDriver()
+	if err != nil {
+		return SQLDialectPostgreSQL, err
+	}
+
+	return driver.Dialect, nil
+}
+
+// getDriver returns driver of DBType or, if DBType is empty, driver detected by DSN scheme.
+func (a *MigratorApp) getDriver() (Driver, error) {
+	dbType := a.DBType
+	if dbType == "" {
+		var err error
+		dbType, err = DBTypeFromDSN(a.DBConnectionDSN)
+		if err != nil {
+			return Driver{}, err
+		}
+	}
+
+	return getDriver(dbType)
+}
@@ -0,0 +1,717 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+func TestMergeMigrationStatuses(t *testing.T) {
+	id1, id2 := uint(1), uint(2)
+	migrateDT := time.Now()
+
+	fileMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", Status: migratorConstants.MigrationStatusUnknown},
+		{Name: "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", Status: migratorConstants.MigrationStatusUnknown},
+	}
+	dbMigrations := []migratorConstants.Migration{
+		{
+			ID:        &id2,
+			Name:      "2024_07_02T10_00_00__removed_file__abcde.sql",
+			Status:    migratorConstants.MigrationStatusFailed,
+			MigrateDT: &migrateDT,
+		},
+		{
+			ID:        &id1,
+			Name:      "2024_07_01T18_04_42__create_foo_table__wyvmi.sql",
+			Status:    migratorConstants.MigrationStatusMigrated,
+			MigrateDT: &migrateDT,
+		},
+	}
+
+	migrations := mergeMigrationStatuses(fileMigrations, dbMigrations)
+	require.Len(t, migrations, 3)
+
+	require.Equal(t, "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", migrations[0].Name)
+	require.Equal(t, migratorConstants.MigrationStatusNew, migrations[0].Status)
+	require.Nil(t, migrations[0].ID)
+	require.False(t, migrations[0].FileMissing)
+
+	require.Equal(t, "2024_07_02T10_00_00__removed_file__abcde.sql", migrations[1].Name)
+	require.Equal(t, migratorConstants.MigrationStatusFailed, migrations[1].Status)
+	require.True(t, migrations[1].FileMissing)
+
+	require.Equal(t, "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", migrations[2].Name)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, migrations[2].Status)
+	require.Equal(t, &id1, migrations[2].ID)
+	require.False(t, migrations[2].FileMissing)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	fileMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", Checksum: "changed"},
+		{Name: "2024_07_02T10_00_00__backfill_foo__abcde.go"},
+		{Name: "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", Checksum: "new"},
+	}
+	dbMigrations := []migratorConstants.Migration{
+		{
+			Name:     "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql",
+			Status:   migratorConstants.MigrationStatusNew,
+			Checksum: "old",
+		},
+		{
+			Name:   "2024_07_02T10_00_00__backfill_foo__abcde.go",
+			Status: migratorConstants.MigrationStatusMigrated,
+		},
+		{
+			Name:     "2024_07_01T18_04_42__create_foo_table__wyvmi.sql",
+			Status:   migratorConstants.MigrationStatusMigrated,
+			Checksum: "original",
+		},
+	}
+
+	statuses := mergeMigrationStatuses(fileMigrations, dbMigrations)
+	require.Len(t, statuses, 3)
+	require.False(t, statuses[0].ChecksumMismatch)
+	require.False(t, statuses[1].ChecksumMismatch)
+	require.True(t, statuses[2].ChecksumMismatch)
+
+	a := &MigratorApp{}
+	err := a.checkChecksums(statuses)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	require.ErrorContains(t, err, "2024_07_01T18_04_42__create_foo_table__wyvmi.sql")
+
+	a.AllowChecksumMismatch = true
+	require.Nil(t, a.checkChecksums(statuses))
+}
+
+func TestMigrationsFS(t *testing.T) {
+	migrationsFS := fstest.MapFS{
+		"migrations/2024_07_01T18_04_42__create_foo_table__wyvmi.sql": {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+		"migrations/2024_07_03T21_23_16__alter_foo_table__flxZZ.sql": {
+			Data: []byte("-- migration: up\nALTER TABLE foo ADD COLUMN name TEXT;\n-- migration: down\nSELECT 1;\n"),
+		},
+		"migrations/README.md": {Data: []byte("not a migration")},
+	}
+
+	ctx := context.Background()
+	a := New("./migrations", "sqlite://"+filepath.Join(t.TempDir(), "test.db"), "")
+	a.MigrationsFS = migrationsFS
+	defer a.Close()
+
+	result, err := a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 2)
+
+	migrations, err := a.Status(ctx)
+	require.Nil(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, migrations[0].Status)
+	require.Equal(t, "migrations/2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", migrations[0].FilePath)
+
+	result, err = a.Down(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+	require.Equal(t, "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", result.Steps[0].Migration)
+
+	validationErrors, err := a.Validate()
+	require.Nil(t, err)
+	require.Len(t, validationErrors, 1)
+}
+
+func TestExternalDB(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_txlock=immediate")
+	require.Nil(t, err)
+	defer db.Close()
+
+	logs := &bytes.Buffer{}
+	a := New("", "", DBTypeSQLite)
+	a.MigrationsFS = fstest.MapFS{
+		"2024_07_01T18_04_42__create_foo_table__wyvmi.sql": {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+	}
+	a.DB = db
+	a.Logger = slog.New(slog.NewTextHandler(logs, nil))
+
+	result, err := a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+	require.Contains(t, logs.String(), `msg="Success migration" migration=2024_07_01T18_04_42__create_foo_table__wyvmi.sql`)
+
+	dbVersion, err := a.GetDBVersion(ctx)
+	require.Nil(t, err)
+	require.Equal(t, uint(1), dbVersion)
+
+	require.Nil(t, a.Close())
+	// Переданное подключение не закрывается приложением.
+	require.Nil(t, db.PingContext(ctx))
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.Down(canceledCtx)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunLock(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	migrations := fstest.MapFS{
+		"2024_07_01T18_04_42__create_foo_table__wyvmi.sql": {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+	}
+
+	holder := New("", dsn, "")
+	holder.MigrationsFS = migrations
+	defer holder.Close()
+	migrator, unlock, err := holder.lock(ctx)
+	require.Nil(t, err)
+	require.NotNil(t, migrator)
+
+	a := New("", dsn, "")
+	a.MigrationsFS = migrations
+	a.LockTimeout = 300 * time.Millisecond
+	defer a.Close()
+
+	result, err := a.Up(ctx)
+	require.ErrorIs(t, err, migratorConstants.ErrLocked)
+	require.ErrorContains(t, err, migratorConstants.CurrentOwner().String())
+	require.Empty(t, result.Steps)
+
+	a.NoWait = true
+	started := time.Now()
+	_, err = a.Redo(ctx)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+	require.Less(t, time.Since(started), a.LockTimeout, "migrator must not wait for lock")
+
+	// Отмена контекста, например по SIGINT, не выдаётся за блокировку другим мигратором.
+	a.NoWait = false
+	a.LockTimeout = 0
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = a.Up(cancelCtx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, errors.Is(err, ErrLockNotAcquired))
+
+	unlock()
+	result, err = a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+
+	// Блокировка снимается после применения миграций.
+	_, err = holder.Down(ctx)
+	require.Nil(t, err)
+}
+
+func TestRedoChangedMigration(t *testing.T) {
+	ctx := context.Background()
+	migrationName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	migrationsFS := fstest.MapFS{
+		migrationName: {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+	}
+
+	a := New("", "sqlite://"+filepath.Join(t.TempDir(), "test.db"), "")
+	a.MigrationsFS = migrationsFS
+	defer a.Close()
+
+	_, err := a.Up(ctx)
+	require.Nil(t, err)
+
+	migrationsFS[migrationName] = &fstest.MapFile{
+		Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER, name TEXT);\n-- migration: down\nDROP TABLE foo;\n"),
+	}
+	_, err = a.Status(ctx)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// Redo применяет изменённый файл и записывает его checksum.
+	_, err = a.Redo(ctx)
+	require.Nil(t, err)
+
+	statuses, err := a.Status(ctx)
+	require.Nil(t, err)
+	require.Len(t, statuses, 1)
+	require.False(t, statuses[0].ChecksumMismatch)
+}
+
+func TestFix(t *testing.T) {
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	migrationName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+
+	a := New("", "sqlite://"+dbPath, "")
+	a.MigrationsFS = fstest.MapFS{
+		migrationName: {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+	}
+	defer a.Close()
+
+	_, err := a.GetDBVersion(ctx)
+	require.Nil(t, err)
+
+	// Мигратор другого процесса упал во время применения миграции.
+	db, err := sql.Open("sqlite", dbPath)
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations (file_path, file_name, status, create_dt, migrate_dt, owner_pid, owner_host, started_at)
+		VALUES (?, ?, 'migrating', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 1, 'pod-1', CURRENT_TIMESTAMP)`,
+		migrationName,
+		migrationName,
+	)
+	require.Nil(t, err)
+
+	statuses, err := a.Status(ctx)
+	require.Nil(t, err)
+	require.Len(t, statuses, 1)
+	require.True(t, statuses[0].Orphaned)
+
+	_, err = a.Fix(ctx, migratorConstants.MigrationStatusMigrated)
+	require.ErrorContains(t, err, `invalid status "migrated"`)
+
+	fixed, err := a.Fix(ctx, migratorConstants.MigrationStatusNew)
+	require.Nil(t, err)
+	require.Len(t, fixed, 1)
+	require.Equal(t, migrationName, fixed[0].Name)
+	require.Equal(t, migratorConstants.MigrationStatusNew, fixed[0].Status)
+
+	events, err := a.History(ctx, migrationName, time.Time{})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, migratorConstants.MigrationDirectionFix, events[0].Direction)
+	require.Equal(t, migratorConstants.MigrationStatusMigrating, events[0].FromStatus)
+
+	result, err := a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+}
+
+func TestBaseline(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	barName := "2024_07_02T10_00_00__create_bar_table__aBcDe.sql"
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// Таблица foo уже есть в существующей БД.
+	db, err := sql.Open("sqlite", dbPath)
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "CREATE TABLE foo (id INTEGER)")
+	require.Nil(t, err)
+
+	a := New("", "sqlite://"+dbPath, "")
+	a.MigrationsFS = fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+		barName: {Data: []byte("-- migration: up\nCREATE TABLE bar (id INTEGER);\n-- migration: down\nDROP TABLE bar;\n")},
+	}
+	defer a.Close()
+
+	_, err = a.Baseline(ctx, "")
+	require.ErrorIs(t, err, ErrBaselineTargetRequired)
+	_, err = a.Baseline(ctx, "2024_07_03T00_00_00")
+	require.ErrorIs(t, err, ErrUnknownMigration)
+
+	result, err := a.Baseline(ctx, "2024_07_01T18_04_42")
+	require.Nil(t, err)
+	require.Equal(t, []RunStep{{
+		Direction: migratorConstants.MigrationDirectionBaseline,
+		Migration: fooName,
+		Status:    migratorConstants.MigrationStatusMigrated,
+	}}, result.Steps)
+
+	// Повторный baseline ничего не меняет.
+	result, err = a.Baseline(ctx, "2024_07_01T18_04_42")
+	require.Nil(t, err)
+	require.Empty(t, result.Steps)
+
+	result, err = a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+	require.Equal(t, barName, result.Steps[0].Migration)
+
+	statuses, err := a.Status(ctx)
+	require.Nil(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, fooName, statuses[1].Name)
+	require.True(t, statuses[1].Baselined)
+	require.False(t, statuses[0].Baselined)
+}
+
+func TestMark(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	barName := "2024_07_02T10_00_00__create_bar_table__aBcDe.sql"
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	a := New("", "sqlite://"+dbPath, "")
+	a.MigrationsFS = fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+		barName: {Data: []byte("-- migration: up\nCREATE TABLE bar (id INTEGER);\n-- migration: down\nDROP TABLE bar;\n")},
+	}
+	defer a.Close()
+
+	_, err := a.Mark(ctx, "2024_07_01T18_04_42", migratorConstants.MigrationStatusMigrating)
+	require.ErrorContains(t, err, `invalid status "migrating"`)
+	_, err = a.Mark(ctx, "2024_07_03T00_00_00", migratorConstants.MigrationStatusMigrated)
+	require.ErrorIs(t, err, ErrUnknownMigration)
+
+	// Миграция foo применена вручную.
+	migration, err := a.Mark(ctx, "2024_07_01T18_04_42", migratorConstants.MigrationStatusMigrated)
+	require.Nil(t, err)
+	require.Equal(t, fooName, migration.Name)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, migration.Status)
+	require.NotEmpty(t, migration.Checksum)
+
+	result, err := a.Up(ctx)
+	require.Nil(t, err)
+	require.Len(t, result.Steps, 1)
+	require.Equal(t, barName, result.Steps[0].Migration)
+
+	migration, err = a.Mark(ctx, barName, migratorConstants.MigrationStatusFailed)
+	require.Nil(t, err)
+	require.Equal(t, migratorConstants.MigrationStatusFailed, migration.Status)
+
+	events, err := a.History(ctx, barName, time.Time{})
+	require.Nil(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, migratorConstants.MigrationDirectionMark, events[1].Direction)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, events[1].FromStatus)
+	require.Equal(t, migratorConstants.MigrationStatusFailed, events[1].ToStatus)
+
+	// Миграция, которую применяет другой мигратор, не помечается.
+	db, err := sql.Open("sqlite", dbPath)
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = db.ExecContext(ctx, "UPDATE dbmigrations SET status = 'migrating' WHERE file_name = ?", barName)
+	require.Nil(t, err)
+
+	_, err = a.Mark(ctx, barName, migratorConstants.MigrationStatusNew)
+	require.ErrorIs(t, err, ErrMigrationInProgress)
+}
+
+func TestPlan(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	barName := "2024_07_02T10_00_00__create_bar_table__aBcDe.sql"
+
+	a := New("", "sqlite://"+filepath.Join(t.TempDir(), "test.db"), "")
+	a.MigrationsFS = fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+		barName: {Data: []byte("-- migration: up\nCREATE TABLE bar (id INTEGER);\nCREATE INDEX bar_id ON bar (id);\n" +
+			"-- migration: down\nDROP TABLE bar;\n")},
+	}
+	defer a.Close()
+
+	_, err := a.Plan(ctx, Command("status"))
+	require.ErrorContains(t, err, `unknown command "status"`)
+	_, err = a.Plan(ctx, CommandRedo, WithSteps(2))
+	require.NotNil(t, err)
+
+	plan, err := a.Plan(ctx, CommandUp)
+	require.Nil(t, err)
+	require.Equal(t, CommandUp, plan.Command)
+	require.Len(t, plan.Steps, 2)
+	require.Equal(t, fooName, plan.Steps[0].Migration)
+	require.Equal(t, []string{"CREATE TABLE foo (id INTEGER);"}, plan.Steps[0].Statements)
+	require.Equal(t, barName, plan.Steps[1].Migration)
+	require.Equal(t, []string{"CREATE TABLE bar (id INTEGER);", "CREATE INDEX bar_id ON bar (id);"}, plan.Steps[1].Statements)
+
+	// План не применяет миграции, поэтому foo остаётся первой новой миграцией.
+	plan, err = a.Plan(ctx, CommandUp, WithSteps(1))
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Equal(t, fooName, plan.Steps[0].Migration)
+
+	plan, err = a.Plan(ctx, CommandDown)
+	require.Nil(t, err)
+	require.Empty(t, plan.Steps)
+
+	_, err = a.Up(ctx)
+	require.Nil(t, err)
+
+	plan, err = a.Plan(ctx, CommandUp)
+	require.Nil(t, err)
+	require.Empty(t, plan.Steps, "applied migrations must be left out of plan")
+
+	plan, err = a.Plan(ctx, CommandDown, WithAll())
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 2)
+	require.Equal(t, barName, plan.Steps[0].Migration)
+	require.Equal(t, migratorConstants.MigrationDirectionDown, plan.Steps[0].Direction)
+	require.Equal(t, []string{"DROP TABLE bar;"}, plan.Steps[0].Statements)
+
+	plan, err = a.Plan(ctx, CommandRedo)
+	require.Nil(t, err)
+	require.Equal(t, []PlanStep{
+		{
+			Direction:  migratorConstants.MigrationDirectionDown,
+			Migration:  barName,
+			FilePath:   barName,
+			Statements: []string{"DROP TABLE bar;"},
+		},
+		{
+			Direction:  migratorConstants.MigrationDirectionUp,
+			Migration:  barName,
+			FilePath:   barName,
+			Statements: []string{"CREATE TABLE bar (id INTEGER);", "CREATE INDEX bar_id ON bar (id);"},
+		},
+	}, plan.Steps)
+
+	statuses, err := a.Status(ctx)
+	require.Nil(t, err)
+	for i := range statuses {
+		require.Equal(t, migratorConstants.MigrationStatusMigrated, statuses[i].Status)
+	}
+}
+
+func TestPlanKeepsDatabase(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	a := New("", "sqlite://"+dbPath, "")
+	a.MigrationsFS = fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+	}
+	defer a.Close()
+
+	// Отсутствующий журнал миграций читается как пустой.
+	plan, err := a.Plan(ctx, CommandUp)
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Equal(t, fooName, plan.Steps[0].Migration)
+
+	for _, command := range []Command{CommandDown, CommandRedo, CommandRepair} {
+		plan, err = a.Plan(ctx, command)
+		require.Nil(t, err)
+		require.Empty(t, plan.Steps)
+	}
+	plan, err = a.Plan(ctx, CommandFix, WithStatus(migratorConstants.MigrationStatusFailed))
+	require.Nil(t, err)
+	require.Empty(t, plan.Steps)
+	plan, err = a.Plan(ctx, CommandBaseline, WithTarget(fooName))
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	plan, err = a.Plan(ctx, CommandMark, WithTarget(fooName), WithStatus(migratorConstants.MigrationStatusMigrated))
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+
+	// План не создаёт ни журнал миграций, ни таблицы блокировки и событий.
+	db, err := sql.Open("sqlite", dbPath)
+	require.Nil(t, err)
+	defer db.Close()
+
+	var tables int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables)
+	require.Nil(t, err)
+	require.Zero(t, tables)
+}
+
+func TestPlanChanges(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	barName := "2024_07_02T10_00_00__create_bar_table__aBcDe.sql"
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	fs := fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+		barName: {Data: []byte("-- migration: up\nCREATE TABLE bar (id INTEGER);\n-- migration: down\nDROP TABLE bar;\n")},
+	}
+	a := New("", "sqlite://"+dbPath, "")
+	a.MigrationsFS = fs
+	defer a.Close()
+
+	_, err := a.Plan(ctx, CommandMark, WithTarget(fooName))
+	require.ErrorContains(t, err, `invalid status ""`)
+	_, err = a.Plan(ctx, CommandBaseline)
+	require.ErrorIs(t, err, ErrBaselineTargetRequired)
+	_, err = a.Plan(ctx, CommandFix, WithTarget(fooName), WithStatus(migratorConstants.MigrationStatusNew))
+	require.NotNil(t, err)
+	_, err = a.Plan(ctx, CommandRepair, WithSteps(1))
+	require.NotNil(t, err)
+	_, err = a.Plan(ctx, CommandUp, WithStatus(migratorConstants.MigrationStatusNew))
+	require.NotNil(t, err)
+	_, err = a.Up(ctx, WithStatus(migratorConstants.MigrationStatusNew))
+	require.NotNil(t, err)
+
+	_, err = a.Up(ctx, WithSteps(1))
+	require.Nil(t, err)
+	statuses, err := a.Status(ctx)
+	require.Nil(t, err)
+	require.Len(t, statuses, 2)
+	barChecksum := statuses[0].Checksum
+	fooChecksum := statuses[1].Checksum
+	require.NotEmpty(t, barChecksum)
+	require.NotEmpty(t, fooChecksum)
+
+	// Применённая foo пропускается, bar будет записана с контрольной суммой файла.
+	plan, err := a.Plan(ctx, CommandBaseline, WithTarget(barName))
+	require.Nil(t, err)
+	require.Equal(t, []PlanStep{
+		{
+			Direction:  migratorConstants.MigrationDirectionBaseline,
+			Migration:  barName,
+			FilePath:   barName,
+			FromStatus: migratorConstants.MigrationStatusNew,
+			ToStatus:   migratorConstants.MigrationStatusMigrated,
+			ToChecksum: barChecksum,
+		},
+	}, plan.Steps)
+
+	plan, err = a.Plan(ctx, CommandMark, WithTarget(fooName), WithStatus(migratorConstants.MigrationStatusFailed))
+	require.Nil(t, err)
+	require.Equal(t, []PlanStep{
+		{
+			Direction:  migratorConstants.MigrationDirectionMark,
+			Migration:  fooName,
+			FilePath:   fooName,
+			FromStatus: migratorConstants.MigrationStatusMigrated,
+			ToStatus:   migratorConstants.MigrationStatusFailed,
+		},
+	}, plan.Steps)
+
+	// Файл применённой миграции изменён после применения.
+	fs[fooName] = &fstest.MapFile{
+		Data: []byte("-- migration: up\nCREATE TABLE foo (id BIGINT);\n-- migration: down\nDROP TABLE foo;\n"),
+	}
+	plan, err = a.Plan(ctx, CommandRepair)
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Empty(t, plan.Steps[0].Direction)
+	require.Empty(t, plan.Steps[0].ToStatus)
+	require.Equal(t, fooName, plan.Steps[0].Migration)
+	require.Equal(t, fooChecksum, plan.Steps[0].FromChecksum)
+	require.NotEmpty(t, plan.Steps[0].ToChecksum)
+	require.NotEqual(t, fooChecksum, plan.Steps[0].ToChecksum)
+
+	plan, err = a.Plan(ctx, CommandMark, WithTarget(fooName), WithStatus(migratorConstants.MigrationStatusMigrated))
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Equal(t, fooChecksum, plan.Steps[0].FromChecksum)
+	require.NotEqual(t, fooChecksum, plan.Steps[0].ToChecksum)
+
+	// Мигратор другого процесса упал во время применения bar.
+	db, err := sql.Open("sqlite", dbPath)
+	require.Nil(t, err)
+	defer db.Close()
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO dbmigrations (file_path, file_name, status, create_dt, migrate_dt, owner_pid, owner_host, started_at)
+		VALUES (?, ?, 'migrating', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 1, 'pod-1', CURRENT_TIMESTAMP)`,
+		barName,
+		barName,
+	)
+	require.Nil(t, err)
+
+	plan, err = a.Plan(ctx, CommandFix, WithStatus(migratorConstants.MigrationStatusNew))
+	require.Nil(t, err)
+	require.Len(t, plan.Steps, 1)
+	require.Equal(t, migratorConstants.MigrationDirectionFix, plan.Steps[0].Direction)
+	require.Equal(t, barName, plan.Steps[0].Migration)
+	require.Equal(t, migratorConstants.MigrationStatusMigrating, plan.Steps[0].FromStatus)
+	require.Equal(t, migratorConstants.MigrationStatusNew, plan.Steps[0].ToStatus)
+
+	plan, err = a.Plan(ctx, CommandBaseline, WithTarget(barName))
+	require.Nil(t, err)
+	require.Empty(t, plan.Steps, "migrating migration must be skipped by baseline")
+
+	// План не меняет журнал миграций и не записывает события.
+	a.AllowChecksumMismatch = true
+	statuses, err = a.Status(ctx)
+	require.Nil(t, err)
+	require.Equal(t, migratorConstants.MigrationStatusMigrating, statuses[0].Status)
+	require.True(t, statuses[0].Orphaned)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, statuses[1].Status)
+	require.Equal(t, fooChecksum, statuses[1].Checksum)
+
+	events, err := a.History(ctx, "", time.Time{})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestShowFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	migrationName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+
+	a := New("", "sqlite://"+filepath.Join(t.TempDir(), "test.db"), "")
+	a.MigrationsFS = fstest.MapFS{
+		migrationName: {
+			Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n"),
+		},
+	}
+	defer a.Close()
+
+	_, err := a.Up(ctx)
+	require.NotNil(t, err)
+
+	migration, err := a.Show(ctx, "2024_07_01T18_04_42")
+	require.Nil(t, err)
+	require.Equal(t, migrationName, migration.Name)
+	require.Equal(t, migratorConstants.MigrationStatusFailed, migration.Status)
+	require.Contains(t, migration.ErrorMessage, "table foo already exists")
+	require.Equal(t, "SQLITE_ERROR", migration.ErrorCode)
+	require.NotNil(t, migration.ErrorStatement)
+	require.Equal(t, 2, *migration.ErrorStatement)
+	require.NotNil(t, migration.DurationMS)
+
+	_, err = a.Show(ctx, "2024_07_02T00_00_00")
+	require.ErrorIs(t, err, ErrUnknownMigration)
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	fooName := "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"
+	barName := "2024_07_02T10_00_00__create_bar_table__aBcDe.sql"
+
+	a := New("", "sqlite://"+filepath.Join(t.TempDir(), "test.db"), "")
+	a.ToolVersion = "v1.2.3"
+	a.MigrationsFS = fstest.MapFS{
+		fooName: {Data: []byte("-- migration: up\nCREATE TABLE foo (id INTEGER);\n-- migration: down\nDROP TABLE foo;\n")},
+		barName: {Data: []byte("-- migration: up\nCREATE TABLE bar (id INTEGER);\n-- migration: down\nDROP TABLE bar;\n")},
+	}
+	defer a.Close()
+
+	_, err := a.Up(ctx)
+	require.Nil(t, err)
+	_, err = a.Redo(ctx)
+	require.Nil(t, err)
+
+	events, err := a.History(ctx, "", time.Time{})
+	require.Nil(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, fooName, events[0].Migration)
+	require.Equal(t, barName, events[1].Migration)
+	require.Equal(t, "v1.2.3", events[0].ToolVersion)
+
+	events, err = a.History(ctx, "2024_07_02T10_00_00", time.Time{})
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, migratorConstants.MigrationDirectionUp, events[0].Direction)
+	require.Equal(t, migratorConstants.MigrationDirectionDown, events[1].Direction)
+	require.Equal(t, migratorConstants.MigrationStatusMigrated, events[1].FromStatus)
+	require.Equal(t, migratorConstants.MigrationStatusNew, events[1].ToStatus)
+	require.Equal(t, migratorConstants.MigrationDirectionUp, events[2].Direction)
+
+	events, err = a.History(ctx, "", time.Now().Add(time.Hour))
+	require.Nil(t, err)
+	require.Empty(t, events)
+
+	_, err = a.History(ctx, "2024_07_03T00_00_00", time.Time{})
+	require.ErrorIs(t, err, ErrUnknownMigration)
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// ErrBaselineTargetRequired is returned by Baseline called without target migration.
+var ErrBaselineTargetRequired = errors.New("target migration of baseline is required")
+
+// Baseline creates migrations log in existing database and records migrations up to the target
+// migration inclusive as migrated without applying them, so Up applies only migrations after the target.
+// It is used for databases whose schema already contains changes of these migrations.
+// Already applied migrations are skipped. Returns migrations recorded by the call.
+func (a *MigratorApp) Baseline(ctx context.Context, target string) (RunResult, error) {
+	result := RunResult{}
+	if target == "" {
+		return result, ErrBaselineTargetRequired
+	}
+
+	migrator, unlock, err := a.lock(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	migrations, err := a.baselineMigrations(target)
+	if err != nil {
+		return result, err
+	}
+
+	baselined, err := migrator.Baseline(ctx, migrations)
+	result.add(migratorConstants.MigrationDirectionBaseline, baselined)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// baselineMigrations returns migration files up to the target migration inclusive.
+// Baseline skips those of them which are already applied.
+func (a *MigratorApp) baselineMigrations(target string) ([]migratorConstants.Migration, error) {
+	migrations, err := a.parseMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	targetIdx, err := findMigration(migrations, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrations[:targetIdx+1], nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch of applied migrations")
+
+// checkChecksums returns error if files of applied migrations were changed after applying.
+// If AllowChecksumMismatch is set, mismatches are logged as warnings.
+func (a *MigratorApp) checkChecksums(statuses []migratorConstants.Migration) error {
+	mismatched := []string{}
+	for i := range statuses {
+		if statuses[i].ChecksumMismatch {
+			mismatched = append(mismatched, statuses[i].Name)
+		}
+	}
+
+	if len(mismatched) == 0 {
+		return nil
+	}
+
+	if !a.AllowChecksumMismatch {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(mismatched, ", "))
+	}
+
+	for _, name := range mismatched {
+		a.logger().Warn("Applied migration file was changed", "migration", name)
+	}
+
+	return nil
+}
+
+// Repair records current checksums of applied migration files.
+// It is used after deliberate edit of applied migrations.
+// Returns migrations whose checksums were updated.
+func (a *MigratorApp) Repair(ctx context.Context) ([]migratorConstants.Migration, error) {
+	migrator, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := a.changedChecksums(ctx, migrator)
+	if err != nil {
+		return nil, err
+	}
+
+	repaired := []migratorConstants.Migration{}
+	for _, change := range changed {
+		err = migrator.SetChecksum(ctx, change.migration.Name, change.checksum)
+		if err != nil {
+			return repaired, err
+		}
+
+		change.migration.Checksum = change.checksum
+		repaired = append(repaired, change.migration)
+	}
+
+	return repaired, nil
+}
+
+// checksumChange is an applied migration whose recorded checksum differs from checksum of its file.
+type checksumChange struct {
+	migration migratorConstants.Migration
+	checksum  string
+}
+
+// changedChecksums returns applied migrations whose checksums would be updated by Repair.
+func (a *MigratorApp) changedChecksums(ctx context.Context, migrator Migrator) ([]checksumChange, error) {
+	fileMigrations, err := a.parseMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	dbMigrations, err := migrator.GetMigations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(fileMigrations))
+	for i := range fileMigrations {
+		checksums[fileMigrations[i].Name] = fileMigrations[i].Checksum
+	}
+
+	changed := []checksumChange{}
+	for i := range dbMigrations {
+		checksum := checksums[dbMigrations[i].Name]
+		if dbMigrations[i].Status != migratorConstants.MigrationStatusMigrated ||
+			checksum == "" ||
+			checksum == dbMigrations[i].Checksum {
+			continue
+		}
+
+		changed = append(changed, checksumChange{migration: dbMigrations[i], checksum: checksum})
+	}
+
+	return changed, nil
+}
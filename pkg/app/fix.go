@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// warnOrphaned logs migrations left in migrating status by stopped migrators.
+// Such migrations are skipped by Up until they are reset with Fix.
+func (a *MigratorApp) warnOrphaned(migrations []migratorConstants.Migration) {
+	for i := range migrations {
+		if migrations[i].Orphaned {
+			a.logger().Warn("Migration was left in migrating status by stopped migrator, reset it with fix", "migration", migrations[i].Name)
+		}
+	}
+}
+
+// Fix resets status of orphaned migrations, i.e. migrations left in migrating status by stopped migrator.
+// Status must be failed, if changes of migrations may be applied partially, or new.
+// Returns migrations whose status was reset.
+func (a *MigratorApp) Fix(ctx context.Context, status migratorConstants.MigrationStatus) ([]migratorConstants.Migration, error) {
+	err := validateFixStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, unlock, err := a.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	orphaned, err := orphanedMigrations(ctx, migrator)
+	if err != nil {
+		return nil, err
+	}
+
+	fixed := []migratorConstants.Migration{}
+	for i := range orphaned {
+		err = migrator.SetStatus(ctx, orphaned[i], migratorConstants.MigrationDirectionFix, status)
+		if err != nil {
+			return fixed, err
+		}
+
+		orphaned[i].Status = status
+		orphaned[i].Orphaned = false
+		fixed = append(fixed, orphaned[i])
+	}
+
+	return fixed, nil
+}
+
+func validateFixStatus(status migratorConstants.MigrationStatus) error {
+	if status != migratorConstants.MigrationStatusFailed && status != migratorConstants.MigrationStatusNew {
+		return fmt.Errorf(
+			"invalid status %q of orphaned migrations, use %q or %q",
+			status,
+			migratorConstants.MigrationStatusFailed,
+			migratorConstants.MigrationStatusNew,
+		)
+	}
+
+	return nil
+}
+
+// orphanedMigrations returns migrations whose status would be reset by Fix.
+func orphanedMigrations(ctx context.Context, migrator Migrator) ([]migratorConstants.Migration, error) {
+	dbMigrations, err := migrator.GetMigations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orphaned := []migratorConstants.Migration{}
+	for i := range dbMigrations {
+		if dbMigrations[i].Orphaned {
+			orphaned = append(orphaned, dbMigrations[i])
+		}
+	}
+
+	return orphaned, nil
+}
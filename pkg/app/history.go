@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// History returns events of migrations event log in order they were recorded: every applying,
+// rollback and status change of migrations. Non-empty target selects events of migration matching it,
+// non-zero since selects events started at since or later.
+func (a *MigratorApp) History(ctx context.Context, target string, since time.Time) ([]migratorConstants.Event, error) {
+	migrator, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := migratorConstants.EventFilter{Since: since}
+	if target != "" {
+		dbMigrations, err := migrator.GetMigations(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		fileMigrations, err := a.parseMigrations()
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := mergeMigrationStatuses(fileMigrations, dbMigrations)
+		idx, err := findMigration(statuses, target)
+		if err != nil {
+			return nil, err
+		}
+		filter.Migration = statuses[idx].Name
+	}
+
+	return migrator.GetEvents(ctx, filter)
+}
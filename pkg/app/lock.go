@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// ErrLockNotAcquired is matched by errors of Up, Down and Redo if run lock is held by another migrator
+// and wasn't released within LockTimeout or NoWait is set. Errors of cancelled ctx don't match it.
+var ErrLockNotAcquired = migratorConstants.ErrLocked
+
+// lock connects to database and takes run lock, so only one migrator applies migrations at a time.
+// Returned function releases the lock.
+func (a *MigratorApp) lock(ctx context.Context) (Migrator, func(), error) {
+	migrator, err := a.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if a.NoWait {
+		err = migrator.TryLock(ctx)
+	} else {
+		lockCtx := ctx
+		if a.LockTimeout > 0 {
+			var cancel context.CancelFunc
+			lockCtx, cancel = context.WithTimeout(ctx, a.LockTimeout)
+			defer cancel()
+		}
+
+		err = migrator.Lock(lockCtx)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlock := func() {
+		// Блокировка снимается и после отмены контекста, иначе она осталась бы до закрытия соединения.
+		err := migrator.Unlock(context.WithoutCancel(ctx))
+		if err != nil {
+			a.logger().Warn("Failed to release run lock", "error", err)
+		}
+	}
+
+	return migrator, unlock, nil
+}
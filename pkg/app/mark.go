@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// ErrMigrationInProgress is returned by Mark if migration is being applied by another migrator.
+var ErrMigrationInProgress = errors.New("migration is being applied")
+
+// Mark sets status of migration matching target without applying or rolling it back, e.g. after
+// manual fix of database. Status must be migrated, new or failed. Status is changed under the lock
+// of migration and recorded in migrations event log. Migration marked as migrated gets checksum
+// of its file. Returns migration with the new status.
+func (a *MigratorApp) Mark(
+	ctx context.Context,
+	target string,
+	status migratorConstants.MigrationStatus,
+) (migratorConstants.Migration, error) {
+	err := validateMarkStatus(status)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
+	migrator, unlock, err := a.lock(ctx)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+	defer unlock()
+
+	migration, checksum, err := a.markMigration(ctx, migrator, target)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
+	err = migrator.SetStatus(ctx, migration, migratorConstants.MigrationDirectionMark, status)
+	if err != nil {
+		return migratorConstants.Migration{}, err
+	}
+
+	if status == migratorConstants.MigrationStatusMigrated && checksum != "" {
+		err = migrator.SetChecksum(ctx, migration.Name, checksum)
+		if err != nil {
+			return migratorConstants.Migration{}, err
+		}
+		migration.Checksum = checksum
+	}
+
+	migration.Status = status
+	migration.Orphaned = false
+	migration.Baselined = false
+	migration.ChecksumMismatch = false
+
+	return migration, nil
+}
+
+func validateMarkStatus(status migratorConstants.MigrationStatus) error {
+	switch status {
+	case migratorConstants.MigrationStatusMigrated,
+		migratorConstants.MigrationStatusNew,
+		migratorConstants.MigrationStatusFailed:
+		return nil
+	default:
+		return fmt.Errorf(
+			"invalid status %q of migration, use %q, %q or %q",
+			status,
+			migratorConstants.MigrationStatusMigrated,
+			migratorConstants.MigrationStatusNew,
+			migratorConstants.MigrationStatusFailed,
+		)
+	}
+}
+
+// markMigration returns migration matching target whose status would be set by Mark,
+// together with checksum of its file. Migration not recorded in migrations log has nil ID.
+func (a *MigratorApp) markMigration(
+	ctx context.Context,
+	migrator Migrator,
+	target string,
+) (migratorConstants.Migration, string, error) {
+	dbMigrations, err := migrator.GetMigations(ctx)
+	if err != nil {
+		return migratorConstants.Migration{}, "", err
+	}
+
+	fileMigrations, err := a.parseMigrations()
+	if err != nil {
+		return migratorConstants.Migration{}, "", err
+	}
+
+	statuses := mergeMigrationStatuses(fileMigrations, dbMigrations)
+	idx, err := findMigration(statuses, target)
+	if err != nil {
+		return migratorConstants.Migration{}, "", err
+	}
+
+	migration := statuses[idx]
+	// Осиротевшие миграции можно пометить, их мигратор уже остановлен.
+	if migration.Status == migratorConstants.MigrationStatusMigrating && !migration.Orphaned {
+		return migratorConstants.Migration{}, "", fmt.Errorf("%w: %s", ErrMigrationInProgress, migration.Name)
+	}
+
+	return migration, fileChecksum(fileMigrations, migration.Name), nil
+}
+
+// fileChecksum returns checksum of migration file, empty for Go migrations and missing files.
+func fileChecksum(fileMigrations []migratorConstants.Migration, name string) string {
+	for i := range fileMigrations {
+		if fileMigrations[i].Name == name {
+			return fileMigrations[i].Checksum
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/wursta/gomigrator/internal/utils"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+var (
+	ErrUnknownMigration    = errors.New("unknown migration")
+	ErrAmbiguousMigration  = errors.New("ambiguous migration")
+	ErrMigrationApplied    = errors.New("migration already applied")
+	ErrMigrationNotApplied = errors.New("migration is not applied")
+)
+
+// RunOption configures which migrations are applied by Up or rolled back by Down,
+// or which changes are planned by Plan.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	target string
+	steps  int
+	all    bool
+	status migratorConstants.MigrationStatus
+}
+
+// WithTarget limits migrations by the target migration.
+// Up applies migrations up to the target inclusive,
+// Down rollbacks migrations applied after the target, so the target stays applied.
+// Target is a migration file name, with or without extension, or its datetime prefix.
+func WithTarget(target string) RunOption {
+	return func(o *runOptions) {
+		o.target = target
+	}
+}
+
+// WithSteps limits count of applied or rolled back migrations.
+func WithSteps(steps int) RunOption {
+	return func(o *runOptions) {
+		o.steps = steps
+	}
+}
+
+// WithAll makes Down rollback all applied migrations.
+func WithAll() RunOption {
+	return func(o *runOptions) {
+		o.all = true
+	}
+}
+
+// WithStatus sets status which Plan of mark and fix commands sets to migrations.
+func WithStatus(status migratorConstants.MigrationStatus) RunOption {
+	return func(o *runOptions) {
+		o.status = status
+	}
+}
+
+func newRunOptions(opts []RunOption) (runOptions, error) {
+	options := runOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.steps < 0 {
+		return options, fmt.Errorf("steps must be positive, got %d", options.steps)
+	}
+	if options.steps > 0 && options.target != "" {
+		return options, errors.New("steps and target can't be used together")
+	}
+	if options.all && (options.steps > 0 || options.target != "") {
+		return options, errors.New("all can't be used together with steps or target")
+	}
+
+	return options, nil
+}
+
+// findMigration returns index of migration matching target.
+func findMigration(migrations []migratorConstants.Migration, target string) (int, error) {
+	found := -1
+	for i := range migrations {
+		if !isMigrationMatchTarget(migrations[i].Name, target) {
+			continue
+		}
+
+		if found != -1 {
+			return -1, fmt.Errorf("%w: %s matches %s and %s", ErrAmbiguousMigration, target, migrations[found].Name, migrations[i].Name)
+		}
+		found = i
+	}
+
+	if found == -1 {
+		return -1, fmt.Errorf("%w: %s", ErrUnknownMigration, target)
+	}
+
+	return found, nil
+}
+
+func isMigrationMatchTarget(migrationName, target string) bool {
+	if migrationName == target || strings.TrimSuffix(migrationName, filepath.Ext(migrationName)) == target {
+		return true
+	}
+
+	return len(target) == len(utils.MigrationFileDateTime) && strings.HasPrefix(migrationName, target)
+}
+
+func isApplied(status migratorConstants.MigrationStatus) bool {
+	return status == migratorConstants.MigrationStatusMigrated || status == migratorConstants.MigrationStatusMigrating
+}
+
+// selectUpMigrations returns migrations which should be passed to migrator according to run options.
+func selectUpMigrations(
+	migrations,
+	dbMigrations []migratorConstants.Migration,
+	options runOptions,
+) ([]migratorConstants.Migration, error) {
+	if options.target == "" && options.steps == 0 {
+		return migrations, nil
+	}
+
+	statuses := make(map[string]migratorConstants.MigrationStatus, len(dbMigrations))
+	for i := range dbMigrations {
+		statuses[dbMigrations[i].Name] = dbMigrations[i].Status
+	}
+
+	last := len(migrations) - 1
+	if options.target != "" {
+		targetIdx, err := findMigration(migrations, options.target)
+		if err != nil {
+			return nil, err
+		}
+
+		if isApplied(statuses[migrations[targetIdx].Name]) {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationApplied, migrations[targetIdx].Name)
+		}
+		last = targetIdx
+	}
+
+	pending := []migratorConstants.Migration{}
+	for i := 0; i <= last; i++ {
+		if isApplied(statuses[migrations[i].Name]) {
+			continue
+		}
+		pending = append(pending, migrations[i])
+
+		if options.steps > 0 && len(pending) == options.steps {
+			break
+		}
+	}
+
+	return pending, nil
+}
+
+// selectDownMigrations returns applied migrations which should be rolled back according to run options.
+// dbMigrations must be ordered from the last applied migration to the first one.
+func selectDownMigrations(
+	dbMigrations []migratorConstants.Migration,
+	options runOptions,
+) ([]migratorConstants.Migration, error) {
+	migrated := []migratorConstants.Migration{}
+	for i := range dbMigrations {
+		if dbMigrations[i].Status == migratorConstants.MigrationStatusMigrated {
+			migrated = append(migrated, dbMigrations[i])
+		}
+	}
+
+	if options.target == "" {
+		if options.steps > 0 && options.steps < len(migrated) {
+			return migrated[:options.steps], nil
+		}
+
+		return migrated, nil
+	}
+
+	targetIdx, err := findMigration(migrated, options.target)
+	if errors.Is(err, ErrUnknownMigration) {
+		if dbIdx, dbErr := findMigration(dbMigrations, options.target); dbErr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrMigrationNotApplied, dbMigrations[dbIdx].Name)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return migrated[:targetIdx], nil
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+func TestSelectUpMigrations(t *testing.T) {
+	migrations := []migratorConstants.Migration{
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"},
+		{Name: "2024_07_01T21_02_39__create_bar_table__dlfio.sql"},
+		{Name: "2024_07_02T10_00_00__backfill_foo__abcde.go"},
+		{Name: "2024_07_03T21_23_16__alter_bar_table_add_column_name__flxZZ.sql"},
+	}
+	dbMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", Status: migratorConstants.MigrationStatusMigrated},
+		{Name: "2024_07_01T21_02_39__create_bar_table__dlfio.sql", Status: migratorConstants.MigrationStatusFailed},
+	}
+
+	tests := map[string]struct {
+		opts          []RunOption
+		expectedNames []string
+		expectedErr   error
+	}{
+		"no options": {
+			expectedNames: []string{
+				"2024_07_01T18_04_42__create_foo_table__wyvmi.sql",
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+				"2024_07_03T21_23_16__alter_bar_table_add_column_name__flxZZ.sql",
+			},
+		},
+		"steps": {
+			opts: []RunOption{WithSteps(2)},
+			expectedNames: []string{
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+			},
+		},
+		"steps more than pending": {
+			opts: []RunOption{WithSteps(10)},
+			expectedNames: []string{
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+				"2024_07_03T21_23_16__alter_bar_table_add_column_name__flxZZ.sql",
+			},
+		},
+		"target file name": {
+			opts: []RunOption{WithTarget("2024_07_02T10_00_00__backfill_foo__abcde.go")},
+			expectedNames: []string{
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+			},
+		},
+		"target without extension": {
+			opts:          []RunOption{WithTarget("2024_07_01T21_02_39__create_bar_table__dlfio")},
+			expectedNames: []string{"2024_07_01T21_02_39__create_bar_table__dlfio.sql"},
+		},
+		"target datetime": {
+			opts: []RunOption{WithTarget("2024_07_03T21_23_16")},
+			expectedNames: []string{
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+				"2024_07_03T21_23_16__alter_bar_table_add_column_name__flxZZ.sql",
+			},
+		},
+		"unknown target": {
+			opts:        []RunOption{WithTarget("2024_08_01T00_00_00")},
+			expectedErr: ErrUnknownMigration,
+		},
+		"applied target": {
+			opts:        []RunOption{WithTarget("2024_07_01T18_04_42")},
+			expectedErr: ErrMigrationApplied,
+		},
+	}
+
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			options, err := newRunOptions(tt.opts)
+			require.Nil(t, err)
+
+			selected, err := selectUpMigrations(migrations, dbMigrations, options)
+			if tt.expectedErr != nil {
+				require.True(t, errors.Is(err, tt.expectedErr), err)
+				return
+			}
+
+			require.Nil(t, err)
+			names := make([]string, len(selected))
+			for i := range selected {
+				names[i] = selected[i].Name
+			}
+			require.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func TestNewRunOptionsFailure(t *testing.T) {
+	_, err := newRunOptions([]RunOption{WithSteps(-1)})
+	require.NotNil(t, err)
+
+	_, err = newRunOptions([]RunOption{WithSteps(1), WithTarget("2024_07_01T18_04_42")})
+	require.NotNil(t, err)
+
+	_, err = newRunOptions([]RunOption{WithAll(), WithSteps(1)})
+	require.NotNil(t, err)
+}
+
+func TestSelectDownMigrations(t *testing.T) {
+	dbMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_03T21_23_16__alter_bar_table_add_column_name__flxZZ.sql", Status: migratorConstants.MigrationStatusNew},
+		{Name: "2024_07_02T10_00_00__backfill_foo__abcde.go", Status: migratorConstants.MigrationStatusMigrated},
+		{Name: "2024_07_01T21_02_39__create_bar_table__dlfio.sql", Status: migratorConstants.MigrationStatusMigrated},
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", Status: migratorConstants.MigrationStatusMigrated},
+	}
+
+	tests := map[string]struct {
+		opts          []RunOption
+		expectedNames []string
+		expectedErr   error
+	}{
+		"all": {
+			opts: []RunOption{WithAll()},
+			expectedNames: []string{
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+				"2024_07_01T18_04_42__create_foo_table__wyvmi.sql",
+			},
+		},
+		"steps": {
+			opts: []RunOption{WithSteps(2)},
+			expectedNames: []string{
+				"2024_07_02T10_00_00__backfill_foo__abcde.go",
+				"2024_07_01T21_02_39__create_bar_table__dlfio.sql",
+			},
+		},
+		"target": {
+			opts:          []RunOption{WithTarget("2024_07_01T21_02_39")},
+			expectedNames: []string{"2024_07_02T10_00_00__backfill_foo__abcde.go"},
+		},
+		"last applied target": {
+			opts:          []RunOption{WithTarget("2024_07_02T10_00_00__backfill_foo__abcde.go")},
+			expectedNames: []string{},
+		},
+		"not applied target": {
+			opts:        []RunOption{WithTarget("2024_07_03T21_23_16")},
+			expectedErr: ErrMigrationNotApplied,
+		},
+		"unknown target": {
+			opts:        []RunOption{WithTarget("2024_08_01T00_00_00")},
+			expectedErr: ErrUnknownMigration,
+		},
+	}
+
+	for tName, tt := range tests {
+		t.Run(tName, func(t *testing.T) {
+			options, err := newRunOptions(tt.opts)
+			require.Nil(t, err)
+
+			selected, err := selectDownMigrations(dbMigrations, options)
+			if tt.expectedErr != nil {
+				require.True(t, errors.Is(err, tt.expectedErr), err)
+				return
+			}
+
+			require.Nil(t, err)
+			names := make([]string, len(selected))
+			for i := range selected {
+				names[i] = selected[i].Name
+			}
+			require.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
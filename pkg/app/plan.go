@@ -0,0 +1,373 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// Command is a command whose migrations are planned by Plan.
+type Command string
+
+const (
+	CommandUp       Command = "up"
+	CommandDown     Command = "down"
+	CommandRedo     Command = "redo"
+	CommandMark     Command = "mark"
+	CommandBaseline Command = "baseline"
+	CommandFix      Command = "fix"
+	CommandRepair   Command = "repair"
+)
+
+// PlanStep is a migration which would be applied or rolled back by command,
+// or whose record in migrations log would be changed without running it.
+type PlanStep struct {
+	// Direction of event recorded by step, empty for checksum update by repair.
+	Direction     migratorConstants.MigrationDirection `json:"direction,omitempty" yaml:"direction,omitempty"`
+	Migration     string                               `json:"migration" yaml:"migration"`
+	FilePath      string                               `json:"filePath" yaml:"filePath"`
+	NoTransaction bool                                 `json:"noTransaction" yaml:"noTransaction"`
+	// GoMigration is set for Go migrations, their SQL is known only when they run.
+	GoMigration bool `json:"goMigration" yaml:"goMigration"`
+	// Statements are SQL statements of migration in order of execution.
+	Statements []string `json:"statements" yaml:"statements"`
+	// FromStatus and ToStatus are set for steps changing status of migration without running it.
+	FromStatus migratorConstants.MigrationStatus `json:"fromStatus,omitempty" yaml:"fromStatus,omitempty"`
+	ToStatus   migratorConstants.MigrationStatus `json:"toStatus,omitempty" yaml:"toStatus,omitempty"`
+	// FromChecksum and ToChecksum are set for steps recording new checksum of migration file,
+	// FromChecksum is empty if no checksum was recorded.
+	FromChecksum string `json:"fromChecksum,omitempty" yaml:"fromChecksum,omitempty"`
+	ToChecksum   string `json:"toChecksum,omitempty" yaml:"toChecksum,omitempty"`
+}
+
+// action returns direction of step for plain and table reports, command for steps without direction.
+func (s PlanStep) action(command Command) string {
+	if s.Direction == "" {
+		return string(command)
+	}
+
+	return string(s.Direction)
+}
+
+// notes returns human readable notes about migration for plain and table reports.
+func (s PlanStep) notes() []string {
+	notes := []string{}
+	if s.NoTransaction {
+		notes = append(notes, "no transaction")
+	}
+	if s.GoMigration {
+		notes = append(notes, "Go migration")
+	}
+	if s.ToStatus != "" {
+		notes = append(notes, fmt.Sprintf("status %s -> %s", s.FromStatus, s.ToStatus))
+	}
+	if s.ToChecksum != "" {
+		notes = append(notes, "checksum updated")
+	}
+
+	return notes
+}
+
+// Plan is a list of migrations which would be applied, rolled back or changed by command in order of execution.
+type Plan struct {
+	Command Command    `json:"command" yaml:"command"`
+	Steps   []PlanStep `json:"steps" yaml:"steps"`
+}
+
+func (p *Plan) add(direction migratorConstants.MigrationDirection, migrations []migratorConstants.Migration) {
+	for i := range migrations {
+		_, goMigration := getGoMigration(migrations[i].Name)
+		statements := migrations[i].UpStatements
+		if direction == migratorConstants.MigrationDirectionDown {
+			statements = migrations[i].DownStatements
+		}
+
+		p.Steps = append(p.Steps, PlanStep{
+			Direction:     direction,
+			Migration:     migrations[i].Name,
+			FilePath:      migrations[i].FilePath,
+			NoTransaction: migrations[i].NoTransaction,
+			GoMigration:   goMigration,
+			Statements:    statements,
+		})
+	}
+}
+
+// addChange adds step changing record of migration in migrations log without running it.
+// Status is empty if step doesn't change it, checksum is recorded only if it differs from the recorded one.
+func (p *Plan) addChange(
+	direction migratorConstants.MigrationDirection,
+	migration migratorConstants.Migration,
+	status migratorConstants.MigrationStatus,
+	recordedChecksum,
+	checksum string,
+) {
+	step := PlanStep{
+		Direction: direction,
+		Migration: migration.Name,
+		FilePath:  migration.FilePath,
+	}
+	if status != "" {
+		step.FromStatus = migration.Status
+		step.ToStatus = status
+	}
+	if checksum != "" && checksum != recordedChecksum {
+		step.FromChecksum = recordedChecksum
+		step.ToChecksum = checksum
+	}
+
+	p.Steps = append(p.Steps, step)
+}
+
+// Plan returns migrations which would be applied or rolled back by command with run options,
+// together with their SQL statements, without running them. Migrations are selected like by Up,
+// Down and Redo by comparing migration files with migrations log, so Plan fails in the same cases,
+// e.g. on checksum mismatch. For Mark, Baseline, Fix and Repair the plan lists status and checksum
+// changes of migrations log records, target and status are passed by WithTarget and WithStatus.
+// Plan doesn't change the database: migrations log isn't created, missing log is read as empty,
+// and log of a previous version must be upgraded by another command first.
+// Plan doesn't take run lock, so the plan is outdated if another migrator runs migrations meanwhile.
+func (a *MigratorApp) Plan(ctx context.Context, command Command, opts ...RunOption) (Plan, error) {
+	plan := Plan{Command: command}
+	options, err := newRunOptions(opts)
+	if err != nil {
+		return plan, err
+	}
+
+	err = validateRunOptions(command, options, len(opts) != 0)
+	if err != nil {
+		return plan, err
+	}
+
+	migrator, closeHistory, err := a.history(ctx)
+	if err != nil {
+		return plan, err
+	}
+	defer closeHistory()
+
+	switch command {
+	case CommandUp:
+		migrations, err := a.upMigrations(ctx, migrator, options)
+		if err != nil {
+			return plan, err
+		}
+		plan.add(migratorConstants.MigrationDirectionUp, migrations)
+	case CommandDown:
+		migrations, err := a.downMigrations(ctx, migrator, options)
+		if err != nil {
+			return plan, err
+		}
+		plan.add(migratorConstants.MigrationDirectionDown, migrations)
+	case CommandRedo:
+		migrations, err := a.downMigrations(ctx, migrator, runOptions{steps: 1})
+		if err != nil {
+			return plan, err
+		}
+		plan.add(migratorConstants.MigrationDirectionDown, migrations)
+		plan.add(migratorConstants.MigrationDirectionUp, migrations)
+	case CommandMark:
+		err = a.planMark(ctx, migrator, &plan, options)
+	case CommandBaseline:
+		err = a.planBaseline(ctx, migrator, &plan, options)
+	case CommandFix:
+		err = planFix(ctx, migrator, &plan, options)
+	case CommandRepair:
+		err = a.planRepair(ctx, migrator, &plan)
+	}
+
+	return plan, err
+}
+
+// validateRunOptions returns error if command doesn't support passed run options.
+func validateRunOptions(command Command, options runOptions, hasOptions bool) error {
+	switch command {
+	case CommandUp, CommandDown:
+		if options.status != "" {
+			return fmt.Errorf("%s doesn't support status", command)
+		}
+	case CommandRedo, CommandRepair:
+		if hasOptions {
+			return fmt.Errorf("%s doesn't support run options", command)
+		}
+	case CommandMark:
+		if options.steps > 0 || options.all {
+			return errors.New("mark supports only target and status")
+		}
+		if options.target == "" {
+			return errors.New("target migration of mark is required")
+		}
+		return validateMarkStatus(options.status)
+	case CommandBaseline:
+		if options.steps > 0 || options.all || options.status != "" {
+			return errors.New("baseline supports only target")
+		}
+		if options.target == "" {
+			return ErrBaselineTargetRequired
+		}
+	case CommandFix:
+		if options.target != "" || options.steps > 0 || options.all {
+			return errors.New("fix supports only status")
+		}
+		return validateFixStatus(options.status)
+	default:
+		return fmt.Errorf(
+			"unknown command %q, use %q, %q, %q, %q, %q, %q or %q",
+			command,
+			CommandUp,
+			CommandDown,
+			CommandRedo,
+			CommandMark,
+			CommandBaseline,
+			CommandFix,
+			CommandRepair,
+		)
+	}
+
+	return nil
+}
+
+// planMark adds status change of migration which would be made by Mark.
+func (a *MigratorApp) planMark(ctx context.Context, migrator Migrator, plan *Plan, options runOptions) error {
+	migration, checksum, err := a.markMigration(ctx, migrator, options.target)
+	if err != nil {
+		return err
+	}
+
+	// Контрольная сумма миграции, которой нет в логе, взята из файла, а не записана в БД.
+	recorded := migration.Checksum
+	if migration.ID == nil {
+		recorded = ""
+	}
+	if options.status != migratorConstants.MigrationStatusMigrated {
+		checksum = ""
+	}
+
+	plan.addChange(migratorConstants.MigrationDirectionMark, migration, options.status, recorded, checksum)
+
+	return nil
+}
+
+// planBaseline adds migrations which would be recorded as migrated by Baseline.
+func (a *MigratorApp) planBaseline(ctx context.Context, migrator Migrator, plan *Plan, options runOptions) error {
+	migrations, err := a.baselineMigrations(options.target)
+	if err != nil {
+		return err
+	}
+
+	dbMigrations, err := migrator.GetMigations(ctx)
+	if err != nil {
+		return err
+	}
+
+	dbMigrationsByName := make(map[string]migratorConstants.Migration, len(dbMigrations))
+	for i := range dbMigrations {
+		dbMigrationsByName[dbMigrations[i].Name] = dbMigrations[i]
+	}
+
+	for i := range migrations {
+		migration := migrations[i]
+		migration.Status = migratorConstants.MigrationStatusNew
+		recorded := ""
+		if dbMigration, ok := dbMigrationsByName[migration.Name]; ok {
+			migration.Status = dbMigration.Status
+			recorded = dbMigration.Checksum
+		}
+		// Применённые миграции Baseline пропускает.
+		if isApplied(migration.Status) {
+			continue
+		}
+
+		plan.addChange(
+			migratorConstants.MigrationDirectionBaseline,
+			migration,
+			migratorConstants.MigrationStatusMigrated,
+			recorded,
+			migrations[i].Checksum,
+		)
+	}
+
+	return nil
+}
+
+// planFix adds orphaned migrations whose status would be reset by Fix.
+func planFix(ctx context.Context, migrator Migrator, plan *Plan, options runOptions) error {
+	orphaned, err := orphanedMigrations(ctx, migrator)
+	if err != nil {
+		return err
+	}
+
+	for i := range orphaned {
+		plan.addChange(migratorConstants.MigrationDirectionFix, orphaned[i], options.status, "", "")
+	}
+
+	return nil
+}
+
+// planRepair adds applied migrations whose checksums would be updated by Repair.
+func (a *MigratorApp) planRepair(ctx context.Context, migrator Migrator, plan *Plan) error {
+	changed, err := a.changedChecksums(ctx, migrator)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changed {
+		plan.addChange("", change.migration, "", change.migration.Checksum, change.checksum)
+	}
+
+	return nil
+}
+
+// history returns driver reading migrations log without Init, so the log isn't created or upgraded.
+// Driver connected by the application is reused, otherwise a new driver is connected for the call
+// and closed by returned function.
+func (a *MigratorApp) history(ctx context.Context) (Migrator, func(), error) {
+	a.mu.Lock()
+	migrator := a.migrator
+	a.mu.Unlock()
+	if migrator != nil {
+		return migrator, func() {}, nil
+	}
+
+	migrator, err := a.getMigrator()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = migrator.Connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeHistory := func() {
+		migrator.Close()
+	}
+
+	initialized, err := migrator.Initialized(ctx)
+	if err != nil {
+		closeHistory()
+		return nil, nil, err
+	}
+	if !initialized {
+		return emptyHistory{Migrator: migrator}, closeHistory, nil
+	}
+
+	return migrator, closeHistory, nil
+}
+
+// emptyHistory is a driver whose migrations log doesn't exist yet, the log is read as empty.
+type emptyHistory struct {
+	Migrator
+}
+
+func (emptyHistory) GetLastMigations(
+	context.Context,
+	migratorConstants.MigrationStatus,
+	int,
+) ([]migratorConstants.Migration, error) {
+	return []migratorConstants.Migration{}, nil
+}
+
+func (emptyHistory) GetMigations(context.Context) ([]migratorConstants.Migration, error) {
+	return []migratorConstants.Migration{}, nil
+}
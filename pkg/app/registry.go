@@ -0,0 +1,151 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/wursta/gomigrator/internal/utils"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+var (
+	registryMu   sync.RWMutex
+	goMigrations = map[string]migratorConstants.Migration{}
+)
+
+// AddMigration registers Go migration with up and down handlers.
+// Migration name is taken from the name of the file AddMigration is called from,
+// so it is supposed to be called from init() function of migration file created by
+// "gomigrator create <name> --go" command.
+func AddMigration(up, down migratorConstants.MigrationHandlerContext) {
+	_, filePath, _, ok := runtime.Caller(1)
+	if !ok {
+		panic("gomigrator: can't get caller file name of Go migration")
+	}
+
+	AddNamedMigration(filePath, up, down)
+}
+
+// AddNamedMigration registers Go migration with explicitly passed file path.
+// Base name of the file path is used as migration name and must start with
+// migration creation datetime in utils.MigrationFileDateTime format.
+func AddNamedMigration(filePath string, up, down migratorConstants.MigrationHandlerContext) {
+	if up == nil {
+		up = noopMigrationHandler
+	}
+	if down == nil {
+		down = noopMigrationHandler
+	}
+
+	addMigration(migratorConstants.Migration{
+		FilePath:           filePath,
+		UpHandlerContext:   up,
+		DownHandlerContext: down,
+	})
+}
+
+// AddMigrationNoTx registers Go migration applied outside of transaction on a dedicated connection,
+// e.g. for "CREATE INDEX CONCURRENTLY". Migration name is taken the same way as in AddMigration.
+func AddMigrationNoTx(up, down migratorConstants.MigrationHandlerNoTxContext) {
+	_, filePath, _, ok := runtime.Caller(1)
+	if !ok {
+		panic("gomigrator: can't get caller file name of Go migration")
+	}
+
+	AddNamedMigrationNoTx(filePath, up, down)
+}
+
+// AddNamedMigrationNoTx registers Go migration applied outside of transaction
+// with explicitly passed file path.
+func AddNamedMigrationNoTx(filePath string, up, down migratorConstants.MigrationHandlerNoTxContext) {
+	if up == nil {
+		up = noopMigrationNoTxHandler
+	}
+	if down == nil {
+		down = noopMigrationNoTxHandler
+	}
+
+	addMigration(migratorConstants.Migration{
+		FilePath:               filePath,
+		NoTransaction:          true,
+		UpHandlerNoTxContext:   up,
+		DownHandlerNoTxContext: down,
+	})
+}
+
+func addMigration(migration migratorConstants.Migration) {
+	name := filepath.Base(migration.FilePath)
+	if _, err := utils.ParseMigrationFileDateTime(name); err != nil {
+		panic(fmt.Sprintf("gomigrator: invalid Go migration name %s: %v", name, err))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := goMigrations[name]; exists {
+		panic("gomigrator: duplicate Go migration " + name)
+	}
+
+	migration.Name = name
+	migration.Status = migratorConstants.MigrationStatusUnknown
+	goMigrations[name] = migration
+}
+
+func noopMigrationHandler(context.Context, *sqlx.Tx) error {
+	return nil
+}
+
+func noopMigrationNoTxHandler(context.Context, *sqlx.Conn) error {
+	return nil
+}
+
+func getGoMigration(name string) (migratorConstants.Migration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	migration, ok := goMigrations[name]
+	return migration, ok
+}
+
+func getGoMigrations() []migratorConstants.Migration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	migrations := make([]migratorConstants.Migration, 0, len(goMigrations))
+	for name := range goMigrations {
+		migrations = append(migrations, goMigrations[name])
+	}
+
+	return migrations
+}
+
+// mergeMigrations merges SQL and Go migrations into a single list ordered by
+// migration name, i.e. by migration creation datetime.
+func mergeMigrations(
+	sqlMigrations,
+	goMigrations []migratorConstants.Migration,
+) ([]migratorConstants.Migration, error) {
+	migrations := make([]migratorConstants.Migration, 0, len(sqlMigrations)+len(goMigrations))
+	names := make(map[string]struct{}, cap(migrations))
+
+	for _, list := range [][]migratorConstants.Migration{sqlMigrations, goMigrations} {
+		for i := range list {
+			if _, exists := names[list[i].Name]; exists {
+				return nil, fmt.Errorf("duplicate migration name: %s", list[i].Name)
+			}
+			names[list[i].Name] = struct{}{}
+			migrations = append(migrations, list[i])
+		}
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Name < migrations[j].Name
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+func TestAddNamedMigration(t *testing.T) {
+	defer resetGoMigrations()
+
+	up := func(context.Context, *sqlx.Tx) error { return nil }
+	AddNamedMigration("/migrations/2024_07_02T10_00_00__backfill_foo__abcde.go", up, nil)
+
+	migration, ok := getGoMigration("2024_07_02T10_00_00__backfill_foo__abcde.go")
+	require.True(t, ok)
+	require.Equal(t, "/migrations/2024_07_02T10_00_00__backfill_foo__abcde.go", migration.FilePath)
+	require.NotNil(t, migration.UpHandlerContext)
+	require.NotNil(t, migration.DownHandlerContext)
+
+	require.Panics(t, func() {
+		AddNamedMigration("/other/2024_07_02T10_00_00__backfill_foo__abcde.go", up, up)
+	})
+	require.Panics(t, func() {
+		AddNamedMigration("/migrations/backfill_foo.go", up, up)
+	})
+}
+
+func TestAddNamedMigrationNoTx(t *testing.T) {
+	defer resetGoMigrations()
+
+	up := func(context.Context, *sqlx.Conn) error { return nil }
+	AddNamedMigrationNoTx("/migrations/2024_07_02T10_00_00__create_foo_index__abcde.go", up, nil)
+
+	migration, ok := getGoMigration("2024_07_02T10_00_00__create_foo_index__abcde.go")
+	require.True(t, ok)
+	require.True(t, migration.NoTransaction)
+	require.NotNil(t, migration.UpHandlerNoTxContext)
+	require.NotNil(t, migration.DownHandlerNoTxContext)
+	require.Nil(t, migration.UpHandlerContext)
+
+	require.Panics(t, func() {
+		AddNamedMigration("/migrations/2024_07_02T10_00_00__create_foo_index__abcde.go", nil, nil)
+	})
+}
+
+func TestMergeMigrations(t *testing.T) {
+	sqlMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_01T18_04_42__create_foo_table__wyvmi.sql"},
+		{Name: "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql"},
+	}
+	goMigrations := []migratorConstants.Migration{
+		{Name: "2024_07_04T09_00_00__backfill_foo_name__qwert.go"},
+		{Name: "2024_07_02T10_00_00__backfill_foo__abcde.go"},
+	}
+
+	migrations, err := mergeMigrations(sqlMigrations, goMigrations)
+	require.Nil(t, err)
+	require.Len(t, migrations, 4)
+	require.Equal(t, "2024_07_01T18_04_42__create_foo_table__wyvmi.sql", migrations[0].Name)
+	require.Equal(t, "2024_07_02T10_00_00__backfill_foo__abcde.go", migrations[1].Name)
+	require.Equal(t, "2024_07_03T21_23_16__alter_foo_table__flxZZ.sql", migrations[2].Name)
+	require.Equal(t, "2024_07_04T09_00_00__backfill_foo_name__qwert.go", migrations[3].Name)
+
+	_, err = mergeMigrations(sqlMigrations, sqlMigrations[:1])
+	require.NotNil(t, err)
+}
+
+func resetGoMigrations() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	goMigrations = map[string]migratorConstants.Migration{}
+}
@@ -0,0 +1,548 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+	"gopkg.in/yaml.v3"
+)
+
+type OutputFormat string
+
+// notAppliedStatus is shown in plain and table reports for migrations not applied because of failure.
+const notAppliedStatus = "not applied"
+
+// manualDirection is shown in plain and table reports for events of status set without applying migration
+// recorded without direction by older versions.
+const manualDirection = "manual"
+
+const (
+	OutputFormatPlain OutputFormat = "plain"
+	OutputFormatTable OutputFormat = "table"
+	OutputFormatJSON  OutputFormat = "json"
+	OutputFormatYAML  OutputFormat = "yaml"
+)
+
+// OutputFormats lists all supported output formats.
+var OutputFormats = []OutputFormat{
+	OutputFormatPlain,
+	OutputFormatTable,
+	OutputFormatJSON,
+	OutputFormatYAML,
+}
+
+// RunStep is a single migration applied or rolled back by Up, Down or Redo.
+type RunStep struct {
+	Direction migratorConstants.MigrationDirection `json:"direction" yaml:"direction"`
+	Migration string                               `json:"migration" yaml:"migration"`
+	Status    migratorConstants.MigrationStatus    `json:"status" yaml:"status"`
+}
+
+// RunResult is a result of Up, Down or Redo in order of execution.
+// NotApplied contains migrations which were not applied or rolled back because of failure.
+type RunResult struct {
+	Steps      []RunStep `json:"steps" yaml:"steps"`
+	NotApplied []RunStep `json:"notApplied,omitempty" yaml:"notApplied,omitempty"`
+}
+
+func (r *RunResult) add(direction migratorConstants.MigrationDirection, migrations []migratorConstants.Migration) {
+	for i := range migrations {
+		r.Steps = append(r.Steps, RunStep{
+			Direction: direction,
+			Migration: migrations[i].Name,
+			Status:    migrations[i].Status,
+		})
+	}
+}
+
+func (r *RunResult) addNotApplied(
+	direction migratorConstants.MigrationDirection,
+	migrations []migratorConstants.Migration,
+) {
+	for i := range migrations {
+		r.NotApplied = append(r.NotApplied, RunStep{
+			Direction: direction,
+			Migration: migrations[i].Name,
+			Status:    migrations[i].Status,
+		})
+	}
+}
+
+// MigrationReport is a representation of migration status in reports.
+type MigrationReport struct {
+	ID               *uint                             `json:"id,omitempty" yaml:"id,omitempty"`
+	Name             string                            `json:"name" yaml:"name"`
+	FilePath         string                            `json:"filePath" yaml:"filePath"`
+	Status           migratorConstants.MigrationStatus `json:"status" yaml:"status"`
+	CreatedAt        time.Time                         `json:"createdAt" yaml:"createdAt"`
+	MigratedAt       *time.Time                        `json:"migratedAt,omitempty" yaml:"migratedAt,omitempty"`
+	FileMissing      bool                              `json:"fileMissing" yaml:"fileMissing"`
+	ChecksumMismatch bool                              `json:"checksumMismatch" yaml:"checksumMismatch"`
+	OwnerPID         *int64                            `json:"ownerPid,omitempty" yaml:"ownerPid,omitempty"`
+	OwnerHost        string                            `json:"ownerHost,omitempty" yaml:"ownerHost,omitempty"`
+	StartedAt        *time.Time                        `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	Orphaned         bool                              `json:"orphaned" yaml:"orphaned"`
+	DurationMS       *int64                            `json:"durationMs,omitempty" yaml:"durationMs,omitempty"`
+	Error            string                            `json:"error,omitempty" yaml:"error,omitempty"`
+	ErrorCode        string                            `json:"errorCode,omitempty" yaml:"errorCode,omitempty"`
+	ErrorStatement   *int                              `json:"errorStatement,omitempty" yaml:"errorStatement,omitempty"`
+	Baselined        bool                              `json:"baselined,omitempty" yaml:"baselined,omitempty"`
+}
+
+// notes returns human readable notes about migration problems and baselining.
+func (r MigrationReport) notes() []string {
+	notes := []string{}
+	if r.FileMissing {
+		notes = append(notes, "file missing")
+	}
+	if r.ChecksumMismatch {
+		notes = append(notes, "checksum mismatch")
+	}
+	if r.Orphaned {
+		notes = append(notes, "orphaned, run fix")
+	}
+	if r.Baselined {
+		notes = append(notes, "baselined")
+	}
+	if r.Status == migratorConstants.MigrationStatusFailed && r.Error != "" {
+		notes = append(notes, r.failure())
+	}
+
+	return notes
+}
+
+// failure returns short description of migration error, e.g. "failed with 42P07 at statement #2".
+func (r MigrationReport) failure() string {
+	failure := "failed"
+	if r.ErrorCode != "" {
+		failure += " with " + r.ErrorCode
+	}
+	if r.ErrorStatement != nil {
+		failure += fmt.Sprintf(" at statement #%d", *r.ErrorStatement)
+	}
+
+	return failure
+}
+
+// fields returns migration details as pairs of name and value for plain and table reports.
+func (r MigrationReport) fields() [][]string {
+	fields := [][]string{
+		{"Name", r.Name},
+		{"File", r.FilePath},
+		{"Status", string(r.Status)},
+		{"Created at", r.CreatedAt.Format(time.DateTime)},
+		{"Migrated at", formatMigratedAt(r.MigratedAt)},
+	}
+	if r.DurationMS != nil {
+		fields = append(fields, []string{"Duration", (time.Duration(*r.DurationMS) * time.Millisecond).String()})
+	}
+	if r.Error != "" {
+		fields = append(fields, []string{"Error", r.Error})
+	}
+	if r.ErrorCode != "" {
+		fields = append(fields, []string{"Error code", r.ErrorCode})
+	}
+	if r.ErrorStatement != nil {
+		fields = append(fields, []string{"Failed statement", fmt.Sprint(*r.ErrorStatement)})
+	}
+	if r.OwnerPID != nil {
+		owner := migratorConstants.Owner{PID: int(*r.OwnerPID), Host: r.OwnerHost}
+		fields = append(fields, []string{"Owner", owner.String()})
+	}
+	if notes := r.notes(); len(notes) != 0 {
+		fields = append(fields, []string{"Notes", strings.Join(notes, ", ")})
+	}
+
+	return fields
+}
+
+// EventReport is a representation of migrations event log record in reports.
+type EventReport struct {
+	ID          uint                                 `json:"id" yaml:"id"`
+	Migration   string                               `json:"migration" yaml:"migration"`
+	Direction   migratorConstants.MigrationDirection `json:"direction,omitempty" yaml:"direction,omitempty"`
+	FromStatus  migratorConstants.MigrationStatus    `json:"fromStatus" yaml:"fromStatus"`
+	ToStatus    migratorConstants.MigrationStatus    `json:"toStatus" yaml:"toStatus"`
+	StartedAt   time.Time                            `json:"startedAt" yaml:"startedAt"`
+	FinishedAt  time.Time                            `json:"finishedAt" yaml:"finishedAt"`
+	DurationMS  int64                                `json:"durationMs" yaml:"durationMs"`
+	OSUser      string                               `json:"osUser" yaml:"osUser"`
+	Hostname    string                               `json:"hostname" yaml:"hostname"`
+	ToolVersion string                               `json:"toolVersion,omitempty" yaml:"toolVersion,omitempty"`
+	Error       string                               `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// direction returns direction of event for plain and table reports.
+func (r EventReport) direction() string {
+	if r.Direction == "" {
+		return manualDirection
+	}
+
+	return string(r.Direction)
+}
+
+func (r EventReport) duration() string {
+	return (time.Duration(r.DurationMS) * time.Millisecond).String()
+}
+
+type historyReport struct {
+	Events []EventReport `json:"events" yaml:"events"`
+}
+
+type statusReport struct {
+	Migrations []MigrationReport `json:"migrations" yaml:"migrations"`
+}
+
+type dbVersionReport struct {
+	Version uint `json:"version" yaml:"version"`
+}
+
+// Reporter writes results of commands in some output format.
+type Reporter interface {
+	ReportStatus(migrations []migratorConstants.Migration) error
+	ReportDBVersion(version uint) error
+	ReportRun(result RunResult) error
+	ReportMigration(migration migratorConstants.Migration) error
+	ReportHistory(events []migratorConstants.Event) error
+	ReportPlan(plan Plan) error
+}
+
+// NewReporter returns reporter writing results to w in passed format.
+func NewReporter(format OutputFormat, w io.Writer) (Reporter, error) {
+	switch format {
+	case OutputFormatPlain:
+		return &plainReporter{w: w}, nil
+	case OutputFormatTable:
+		return &tableReporter{w: w}, nil
+	case OutputFormatJSON:
+		return &encodingReporter{encode: func(v any) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(v)
+		}}, nil
+	case OutputFormatYAML:
+		return &encodingReporter{encode: func(v any) error {
+			encoder := yaml.NewEncoder(w)
+			defer encoder.Close()
+			return encoder.Encode(v)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func newMigrationReports(migrations []migratorConstants.Migration) []MigrationReport {
+	reports := make([]MigrationReport, len(migrations))
+	for i := range migrations {
+		reports[i] = MigrationReport{
+			ID:               migrations[i].ID,
+			Name:             migrations[i].Name,
+			FilePath:         migrations[i].FilePath,
+			Status:           migrations[i].Status,
+			CreatedAt:        migrations[i].CreateDT,
+			MigratedAt:       migrations[i].MigrateDT,
+			FileMissing:      migrations[i].FileMissing,
+			ChecksumMismatch: migrations[i].ChecksumMismatch,
+			OwnerPID:         migrations[i].OwnerPID,
+			OwnerHost:        migrations[i].OwnerHost,
+			StartedAt:        migrations[i].StartedAt,
+			Orphaned:         migrations[i].Orphaned,
+			DurationMS:       migrations[i].DurationMS,
+			Error:            migrations[i].ErrorMessage,
+			ErrorCode:        migrations[i].ErrorCode,
+			ErrorStatement:   migrations[i].ErrorStatement,
+			Baselined:        migrations[i].Baselined,
+		}
+	}
+
+	return reports
+}
+
+func newEventReports(events []migratorConstants.Event) []EventReport {
+	reports := make([]EventReport, len(events))
+	for i := range events {
+		reports[i] = EventReport{
+			ID:          events[i].ID,
+			Migration:   events[i].Migration,
+			Direction:   events[i].Direction,
+			FromStatus:  events[i].FromStatus,
+			ToStatus:    events[i].ToStatus,
+			StartedAt:   events[i].StartedAt,
+			FinishedAt:  events[i].FinishedAt,
+			DurationMS:  events[i].DurationMS,
+			OSUser:      events[i].OSUser,
+			Hostname:    events[i].Hostname,
+			ToolVersion: events[i].ToolVersion,
+			Error:       events[i].Error,
+		}
+	}
+
+	return reports
+}
+
+type encodingReporter struct {
+	encode func(v any) error
+}
+
+func (r *encodingReporter) ReportStatus(migrations []migratorConstants.Migration) error {
+	return r.encode(statusReport{Migrations: newMigrationReports(migrations)})
+}
+
+func (r *encodingReporter) ReportDBVersion(version uint) error {
+	return r.encode(dbVersionReport{Version: version})
+}
+
+func (r *encodingReporter) ReportRun(result RunResult) error {
+	if result.Steps == nil {
+		result.Steps = []RunStep{}
+	}
+
+	return r.encode(result)
+}
+
+func (r *encodingReporter) ReportMigration(migration migratorConstants.Migration) error {
+	return r.encode(newMigrationReports([]migratorConstants.Migration{migration})[0])
+}
+
+func (r *encodingReporter) ReportHistory(events []migratorConstants.Event) error {
+	return r.encode(historyReport{Events: newEventReports(events)})
+}
+
+func (r *encodingReporter) ReportPlan(plan Plan) error {
+	if plan.Steps == nil {
+		plan.Steps = []PlanStep{}
+	}
+	for i := range plan.Steps {
+		if plan.Steps[i].Statements == nil {
+			plan.Steps[i].Statements = []string{}
+		}
+	}
+
+	return r.encode(plan)
+}
+
+type plainReporter struct {
+	w io.Writer
+}
+
+func (r *plainReporter) ReportStatus(migrations []migratorConstants.Migration) error {
+	for _, report := range newMigrationReports(migrations) {
+		name := report.Name
+		if notes := report.notes(); len(notes) != 0 {
+			name += " (" + strings.Join(notes, ", ") + ")"
+		}
+
+		_, err := fmt.Fprintf(r.w, "%s | %s | %s\n", report.Status, formatMigratedAt(report.MigratedAt), name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *plainReporter) ReportDBVersion(version uint) error {
+	_, err := fmt.Fprintln(r.w, "Version:", version)
+	return err
+}
+
+func (r *plainReporter) ReportRun(result RunResult) error {
+	for _, step := range result.Steps {
+		_, err := fmt.Fprintf(r.w, "%s | %s | %s\n", step.Direction, step.Status, step.Migration)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, step := range result.NotApplied {
+		_, err := fmt.Fprintf(r.w, "%s | %s | %s\n", step.Direction, notAppliedStatus, step.Migration)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *plainReporter) ReportMigration(migration migratorConstants.Migration) error {
+	report := newMigrationReports([]migratorConstants.Migration{migration})[0]
+	for _, field := range report.fields() {
+		_, err := fmt.Fprintf(r.w, "%s: %s\n", field[0], field[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *plainReporter) ReportHistory(events []migratorConstants.Event) error {
+	for _, report := range newEventReports(events) {
+		line := fmt.Sprintf(
+			"%s | %s | %s -> %s | %s | %s | %s@%s",
+			report.StartedAt.UTC().Format(time.DateTime),
+			report.direction(),
+			report.FromStatus,
+			report.ToStatus,
+			report.Migration,
+			report.duration(),
+			report.OSUser,
+			report.Hostname,
+		)
+		if report.Error != "" {
+			line += " | " + report.Error
+		}
+
+		_, err := fmt.Fprintln(r.w, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReportPlan writes statements of plan as SQL script with a comment before each migration,
+// changes of migrations log records are written as comments only.
+func (r *plainReporter) ReportPlan(plan Plan) error {
+	for i, step := range plan.Steps {
+		if i > 0 {
+			if _, err := fmt.Fprintln(r.w); err != nil {
+				return err
+			}
+		}
+
+		header := fmt.Sprintf("-- %s: %s", step.action(plan.Command), step.Migration)
+		if notes := step.notes(); len(notes) != 0 {
+			header += " (" + strings.Join(notes, ", ") + ")"
+		}
+		if _, err := fmt.Fprintln(r.w, header); err != nil {
+			return err
+		}
+
+		for _, statement := range step.Statements {
+			if !strings.HasSuffix(statement, ";") {
+				statement += ";"
+			}
+			if _, err := fmt.Fprintln(r.w, statement); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type tableReporter struct {
+	w io.Writer
+}
+
+func (r *tableReporter) write(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(r.w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, strings.Join(header, "\t")); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(tw, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (r *tableReporter) ReportStatus(migrations []migratorConstants.Migration) error {
+	reports := newMigrationReports(migrations)
+	rows := make([][]string, len(reports))
+	for i, report := range reports {
+		rows[i] = []string{
+			string(report.Status),
+			formatMigratedAt(report.MigratedAt),
+			report.Name,
+			strings.Join(report.notes(), ", "),
+		}
+	}
+
+	return r.write([]string{"STATUS", "MIGRATED AT", "NAME", "NOTES"}, rows)
+}
+
+func (r *tableReporter) ReportDBVersion(version uint) error {
+	return r.write([]string{"VERSION"}, [][]string{{fmt.Sprint(version)}})
+}
+
+func (r *tableReporter) ReportRun(result RunResult) error {
+	rows := make([][]string, 0, len(result.Steps)+len(result.NotApplied))
+	for _, step := range result.Steps {
+		rows = append(rows, []string{string(step.Direction), string(step.Status), step.Migration})
+	}
+	for _, step := range result.NotApplied {
+		rows = append(rows, []string{string(step.Direction), notAppliedStatus, step.Migration})
+	}
+
+	return r.write([]string{"DIRECTION", "STATUS", "MIGRATION"}, rows)
+}
+
+func (r *tableReporter) ReportMigration(migration migratorConstants.Migration) error {
+	report := newMigrationReports([]migratorConstants.Migration{migration})[0]
+	return r.write([]string{"FIELD", "VALUE"}, report.fields())
+}
+
+func (r *tableReporter) ReportHistory(events []migratorConstants.Event) error {
+	reports := newEventReports(events)
+	rows := make([][]string, len(reports))
+	for i, report := range reports {
+		rows[i] = []string{
+			report.StartedAt.UTC().Format(time.DateTime),
+			report.direction(),
+			string(report.FromStatus),
+			string(report.ToStatus),
+			report.Migration,
+			report.duration(),
+			report.OSUser,
+			report.Hostname,
+			report.ToolVersion,
+			report.Error,
+		}
+	}
+
+	return r.write(
+		[]string{"STARTED AT", "DIRECTION", "FROM", "TO", "MIGRATION", "DURATION", "USER", "HOST", "VERSION", "ERROR"},
+		rows,
+	)
+}
+
+// ReportPlan writes a row for each statement of plan, statements are written in one line.
+func (r *tableReporter) ReportPlan(plan Plan) error {
+	rows := [][]string{}
+	for _, step := range plan.Steps {
+		notes := strings.Join(step.notes(), ", ")
+		for i, statement := range step.Statements {
+			rows = append(rows, []string{
+				step.action(plan.Command),
+				step.Migration,
+				fmt.Sprint(i + 1),
+				strings.Join(strings.Fields(statement), " "),
+				notes,
+			})
+		}
+		if len(step.Statements) == 0 {
+			rows = append(rows, []string{step.action(plan.Command), step.Migration, "-", "-", notes})
+		}
+	}
+
+	return r.write([]string{"DIRECTION", "MIGRATION", "#", "STATEMENT", "NOTES"}, rows)
+}
+
+func formatMigratedAt(migratedAt *time.Time) string {
+	if migratedAt == nil {
+		return "-"
+	}
+
+	return migratedAt.Format(time.DateTime)
+}
@@ -0,0 +1,494 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	migratorConstants "github.com/wursta/gomigrator/pkg/migrator"
+)
+
+func TestReporterReportStatus(t *testing.T) {
+	id, orphanedID := uint(1), uint(2)
+	ownerPID := int64(42)
+	createDT := time.Date(2024, 7, 5, 18, 51, 7, 0, time.UTC)
+	migrateDT := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	migrations := []migratorConstants.Migration{
+		{
+			Name:     "2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
+			FilePath: "/migrations/2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
+			Status:   migratorConstants.MigrationStatusNew,
+			CreateDT: createDT,
+		},
+		{
+			ID:          &id,
+			Name:        "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+			FilePath:    "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+			Status:      migratorConstants.MigrationStatusMigrated,
+			CreateDT:    createDT,
+			MigrateDT:   &migrateDT,
+			FileMissing: true,
+		},
+		{
+			ID:        &orphanedID,
+			Name:      "2024_07_01T10_00_00__create_table_bar__aBcDe.sql",
+			FilePath:  "/migrations/2024_07_01T10_00_00__create_table_bar__aBcDe.sql",
+			Status:    migratorConstants.MigrationStatusMigrating,
+			CreateDT:  createDT,
+			MigrateDT: &migrateDT,
+			OwnerPID:  &ownerPID,
+			OwnerHost: "pod-1",
+			StartedAt: &migrateDT,
+			Orphaned:  true,
+		},
+	}
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: "new | - | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n" +
+			"migrated | 2024-07-10 12:00:00 | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql (file missing)\n" +
+			"migrating | 2024-07-10 12:00:00 | 2024_07_01T10_00_00__create_table_bar__aBcDe.sql (orphaned, run fix)\n",
+		OutputFormatTable: "STATUS     MIGRATED AT          NAME                                                             NOTES\n" +
+			"new        -                    2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql  \n" +
+			"migrated   2024-07-10 12:00:00  2024_07_05T18_51_07__create_table_foo__hKnRd.sql                 file missing\n" +
+			"migrating  2024-07-10 12:00:00  2024_07_01T10_00_00__create_table_bar__aBcDe.sql                 orphaned, run fix\n",
+		OutputFormatJSON: `{
+  "migrations": [
+    {
+      "name": "2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
+      "filePath": "/migrations/2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql",
+      "status": "new",
+      "createdAt": "2024-07-05T18:51:07Z",
+      "fileMissing": false,
+      "checksumMismatch": false,
+      "orphaned": false
+    },
+    {
+      "id": 1,
+      "name": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "filePath": "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "status": "migrated",
+      "createdAt": "2024-07-05T18:51:07Z",
+      "migratedAt": "2024-07-10T12:00:00Z",
+      "fileMissing": true,
+      "checksumMismatch": false,
+      "orphaned": false
+    },
+    {
+      "id": 2,
+      "name": "2024_07_01T10_00_00__create_table_bar__aBcDe.sql",
+      "filePath": "/migrations/2024_07_01T10_00_00__create_table_bar__aBcDe.sql",
+      "status": "migrating",
+      "createdAt": "2024-07-05T18:51:07Z",
+      "migratedAt": "2024-07-10T12:00:00Z",
+      "fileMissing": false,
+      "checksumMismatch": false,
+      "ownerPid": 42,
+      "ownerHost": "pod-1",
+      "startedAt": "2024-07-10T12:00:00Z",
+      "orphaned": true
+    }
+  ]
+}
+`,
+		OutputFormatYAML: `migrations:
+    - name: 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql
+      filePath: /migrations/2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql
+      status: new
+      createdAt: 2024-07-05T18:51:07Z
+      fileMissing: false
+      checksumMismatch: false
+      orphaned: false
+    - id: 1
+      name: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      filePath: /migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      status: migrated
+      createdAt: 2024-07-05T18:51:07Z
+      migratedAt: 2024-07-10T12:00:00Z
+      fileMissing: true
+      checksumMismatch: false
+      orphaned: false
+    - id: 2
+      name: 2024_07_01T10_00_00__create_table_bar__aBcDe.sql
+      filePath: /migrations/2024_07_01T10_00_00__create_table_bar__aBcDe.sql
+      status: migrating
+      createdAt: 2024-07-05T18:51:07Z
+      migratedAt: 2024-07-10T12:00:00Z
+      fileMissing: false
+      checksumMismatch: false
+      ownerPid: 42
+      ownerHost: pod-1
+      startedAt: 2024-07-10T12:00:00Z
+      orphaned: true
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportStatus(migrations)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestReporterReportRun(t *testing.T) {
+	result := RunResult{}
+	result.add(migratorConstants.MigrationDirectionDown, []migratorConstants.Migration{
+		{Name: "2024_07_05T18_51_07__create_table_foo__hKnRd.sql", Status: migratorConstants.MigrationStatusNew},
+	})
+	result.add(migratorConstants.MigrationDirectionUp, []migratorConstants.Migration{
+		{Name: "2024_07_05T18_51_07__create_table_foo__hKnRd.sql", Status: migratorConstants.MigrationStatusMigrated},
+	})
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: "down | new | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n" +
+			"up | migrated | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n",
+		OutputFormatJSON: `{
+  "steps": [
+    {
+      "direction": "down",
+      "migration": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "status": "new"
+    },
+    {
+      "direction": "up",
+      "migration": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "status": "migrated"
+    }
+  ]
+}
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportRun(result)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestPlainReporterReportRunNotApplied(t *testing.T) {
+	result := RunResult{}
+	result.add(migratorConstants.MigrationDirectionDown, []migratorConstants.Migration{
+		{Name: "2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql", Status: migratorConstants.MigrationStatusNew},
+	})
+	result.addNotApplied(migratorConstants.MigrationDirectionDown, []migratorConstants.Migration{
+		{Name: "2024_07_05T18_51_07__create_table_foo__hKnRd.sql", Status: migratorConstants.MigrationStatusMigrated},
+	})
+
+	buf := &bytes.Buffer{}
+	reporter, err := NewReporter(OutputFormatPlain, buf)
+	require.Nil(t, err)
+
+	err = reporter.ReportRun(result)
+	require.Nil(t, err)
+	require.Equal(
+		t,
+		"down | new | 2024_07_09T20_34_36__alter_table_foo_add_column_name__oypjB.sql\n"+
+			"down | not applied | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n",
+		buf.String(),
+	)
+}
+
+func TestNewReporterUnknownFormat(t *testing.T) {
+	_, err := NewReporter(OutputFormat("xml"), &bytes.Buffer{})
+	require.NotNil(t, err)
+}
+
+func TestReporterReportMigration(t *testing.T) {
+	id := uint(1)
+	durationMS := int64(1500)
+	errorStatement := 2
+	createDT := time.Date(2024, 7, 5, 18, 51, 7, 0, time.UTC)
+	migrateDT := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	migration := migratorConstants.Migration{
+		ID:             &id,
+		Name:           "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+		FilePath:       "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+		Status:         migratorConstants.MigrationStatusFailed,
+		CreateDT:       createDT,
+		MigrateDT:      &migrateDT,
+		DurationMS:     &durationMS,
+		ErrorMessage:   `ERROR: relation "foo" already exists (SQLSTATE 42P07)`,
+		ErrorCode:      "42P07",
+		ErrorStatement: &errorStatement,
+	}
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: "Name: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n" +
+			"File: /migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql\n" +
+			"Status: failed\n" +
+			"Created at: 2024-07-05 18:51:07\n" +
+			"Migrated at: 2024-07-10 12:00:00\n" +
+			"Duration: 1.5s\n" +
+			"Error: ERROR: relation \"foo\" already exists (SQLSTATE 42P07)\n" +
+			"Error code: 42P07\n" +
+			"Failed statement: 2\n" +
+			"Notes: failed with 42P07 at statement #2\n",
+		OutputFormatJSON: `{
+  "id": 1,
+  "name": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+  "filePath": "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+  "status": "failed",
+  "createdAt": "2024-07-05T18:51:07Z",
+  "migratedAt": "2024-07-10T12:00:00Z",
+  "fileMissing": false,
+  "checksumMismatch": false,
+  "orphaned": false,
+  "durationMs": 1500,
+  "error": "ERROR: relation \"foo\" already exists (SQLSTATE 42P07)",
+  "errorCode": "42P07",
+  "errorStatement": 2
+}
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportMigration(migration)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestReporterReportHistory(t *testing.T) {
+	startedAt := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	events := []migratorConstants.Event{
+		{
+			ID:          1,
+			Migration:   "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+			Direction:   migratorConstants.MigrationDirectionUp,
+			FromStatus:  migratorConstants.MigrationStatusNew,
+			ToStatus:    migratorConstants.MigrationStatusFailed,
+			StartedAt:   startedAt,
+			FinishedAt:  startedAt.Add(1500 * time.Millisecond),
+			DurationMS:  1500,
+			OSUser:      "deploy",
+			Hostname:    "pod-1",
+			ToolVersion: "v1.2.3",
+			Error:       "relation already exists",
+		},
+		{
+			ID:          2,
+			Migration:   "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+			FromStatus:  migratorConstants.MigrationStatusFailed,
+			ToStatus:    migratorConstants.MigrationStatusNew,
+			StartedAt:   startedAt,
+			FinishedAt:  startedAt,
+			OSUser:      "dba",
+			Hostname:    "laptop",
+			ToolVersion: "v1.2.3",
+		},
+	}
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: "2024-07-10 12:00:00 | up | new -> failed | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql" +
+			" | 1.5s | deploy@pod-1 | relation already exists\n" +
+			"2024-07-10 12:00:00 | manual | failed -> new | 2024_07_05T18_51_07__create_table_foo__hKnRd.sql" +
+			" | 0s | dba@laptop\n",
+		OutputFormatJSON: `{
+  "events": [
+    {
+      "id": 1,
+      "migration": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "direction": "up",
+      "fromStatus": "new",
+      "toStatus": "failed",
+      "startedAt": "2024-07-10T12:00:00Z",
+      "finishedAt": "2024-07-10T12:00:01.5Z",
+      "durationMs": 1500,
+      "osUser": "deploy",
+      "hostname": "pod-1",
+      "toolVersion": "v1.2.3",
+      "error": "relation already exists"
+    },
+    {
+      "id": 2,
+      "migration": "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+      "fromStatus": "failed",
+      "toStatus": "new",
+      "startedAt": "2024-07-10T12:00:00Z",
+      "finishedAt": "2024-07-10T12:00:00Z",
+      "durationMs": 0,
+      "osUser": "dba",
+      "hostname": "laptop",
+      "toolVersion": "v1.2.3"
+    }
+  ]
+}
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportHistory(events)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestReporterReportPlan(t *testing.T) {
+	plan := Plan{
+		Command: CommandRedo,
+		Steps: []PlanStep{
+			{
+				Direction:  migratorConstants.MigrationDirectionDown,
+				Migration:  "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				FilePath:   "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				Statements: []string{"DROP TABLE foo;"},
+			},
+			{
+				Direction:  migratorConstants.MigrationDirectionUp,
+				Migration:  "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				FilePath:   "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				Statements: []string{"CREATE TABLE foo (\n  id INTEGER\n);", "CREATE INDEX foo_id ON foo (id)"},
+			},
+			{
+				Direction:   migratorConstants.MigrationDirectionUp,
+				Migration:   "2024_07_06T10_00_00__fill_foo__aBcDe.go",
+				GoMigration: true,
+			},
+		},
+	}
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: `-- down: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+DROP TABLE foo;
+
+-- up: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+CREATE TABLE foo (
+  id INTEGER
+);
+CREATE INDEX foo_id ON foo (id);
+
+-- up: 2024_07_06T10_00_00__fill_foo__aBcDe.go (Go migration)
+`,
+		OutputFormatTable: "DIRECTION  MIGRATION                                         #  STATEMENT                         NOTES\n" +
+			"down       2024_07_05T18_51_07__create_table_foo__hKnRd.sql  1  DROP TABLE foo;                   \n" +
+			"up         2024_07_05T18_51_07__create_table_foo__hKnRd.sql  1  CREATE TABLE foo ( id INTEGER );  \n" +
+			"up         2024_07_05T18_51_07__create_table_foo__hKnRd.sql  2  CREATE INDEX foo_id ON foo (id)   \n" +
+			"up         2024_07_06T10_00_00__fill_foo__aBcDe.go           -  -                                 Go migration\n",
+		OutputFormatYAML: `command: redo
+steps:
+    - direction: down
+      migration: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      filePath: /migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      noTransaction: false
+      goMigration: false
+      statements:
+        - DROP TABLE foo;
+    - direction: up
+      migration: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      filePath: /migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      noTransaction: false
+      goMigration: false
+      statements:
+        - |-
+          CREATE TABLE foo (
+            id INTEGER
+          );
+        - CREATE INDEX foo_id ON foo (id)
+    - direction: up
+      migration: 2024_07_06T10_00_00__fill_foo__aBcDe.go
+      filePath: ""
+      noTransaction: false
+      goMigration: true
+      statements: []
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportPlan(plan)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
+
+func TestReporterReportPlanChanges(t *testing.T) {
+	plan := Plan{
+		Command: CommandRepair,
+		Steps: []PlanStep{
+			{
+				Migration:    "2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				FilePath:     "/migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql",
+				FromChecksum: "1a2b",
+				ToChecksum:   "3c4d",
+			},
+			{
+				Direction:  migratorConstants.MigrationDirectionMark,
+				Migration:  "2024_07_06T10_00_00__fill_foo__aBcDe.sql",
+				FilePath:   "/migrations/2024_07_06T10_00_00__fill_foo__aBcDe.sql",
+				FromStatus: migratorConstants.MigrationStatusFailed,
+				ToStatus:   migratorConstants.MigrationStatusNew,
+			},
+		},
+	}
+
+	tests := map[OutputFormat]string{
+		OutputFormatPlain: `-- repair: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql (checksum updated)
+
+-- mark: 2024_07_06T10_00_00__fill_foo__aBcDe.sql (status failed -> new)
+`,
+		OutputFormatTable: "DIRECTION  MIGRATION                                         #  STATEMENT  NOTES\n" +
+			"repair     2024_07_05T18_51_07__create_table_foo__hKnRd.sql  -  -          checksum updated\n" +
+			"mark       2024_07_06T10_00_00__fill_foo__aBcDe.sql          -  -          status failed -> new\n",
+		OutputFormatYAML: `command: repair
+steps:
+    - migration: 2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      filePath: /migrations/2024_07_05T18_51_07__create_table_foo__hKnRd.sql
+      noTransaction: false
+      goMigration: false
+      statements: []
+      fromChecksum: 1a2b
+      toChecksum: 3c4d
+    - direction: mark
+      migration: 2024_07_06T10_00_00__fill_foo__aBcDe.sql
+      filePath: /migrations/2024_07_06T10_00_00__fill_foo__aBcDe.sql
+      noTransaction: false
+      goMigration: false
+      statements: []
+      fromStatus: failed
+      toStatus: new
+`,
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("case %s", format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			reporter, err := NewReporter(format, buf)
+			require.Nil(t, err)
+
+			err = reporter.ReportPlan(plan)
+			require.Nil(t, err)
+			require.Equal(t, expected, buf.String())
+		})
+	}
+}
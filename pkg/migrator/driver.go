@@ -0,0 +1,63 @@
+package migrator
+
+import "context"
+
+// Driver applies migrations to a database and keeps their statuses in migrations log table.
+//
+// Drivers must follow the contract checked by migratortest.Run:
+//   - Connect and Close may be called several times, Connect of connected driver does nothing.
+//   - Init creates migrations log table if it doesn't exist and upgrades tables created
+//     by previous versions. It is called before any other method except Connect, Close and Initialized
+//     and may be called concurrently by several processes.
+//   - Initialized reports whether migrations log table exists. Unlike other methods it may be called
+//     before Init, it doesn't change the database.
+//   - Up applies migrations in passed order with UpHandlerContext in transaction, or with
+//     UpHandlerNoTxContext on a dedicated connection if NoTransaction is set.
+//     Migrations with migrated or migrating status are skipped. Each migration is applied under a lock,
+//     so concurrent Up calls of several processes apply every migration exactly once.
+//     Lock of migration is awaited within Options.LockTimeout, or not awaited if Options.NoWait is set.
+//     Applied migration gets migrated status and its Checksum, if not empty, is recorded by the same statement.
+//     On failure Up stops, sets failed status of the migration and returns migrations applied
+//     by the call together with an error wrapping the handler error.
+//     Duration of applying is recorded, and error of failed migration with its code and index
+//     of the failed statement.
+//   - Down rolls back passed migrations in passed order with DownHandlerContext or DownHandlerNoTxContext
+//     and sets new status. On failure Down stops and returns an error wrapping the handler error,
+//     status of the migration stays migrated if the database rolled back the transaction,
+//     or becomes failed if changes could be applied partially.
+//   - GetLastMigations returns up to count migrations with status, GetMigations returns all migrations
+//     of migrations log. Both are ordered by ID descending, i.e. the latest applied migration goes first.
+//   - SetChecksum records checksum of migration file.
+//   - SetStatus records status of migration without applying it, migration is registered
+//     in migrations log if needed. Event of the change gets passed direction, e.g. MigrationDirectionMark.
+//   - Baseline records passed migrations as migrated and baselined without applying them,
+//     e.g. for existing database whose schema already contains their changes. Migrations with
+//     migrated or migrating status are skipped, Checksum is recorded if not empty. Baseline returns
+//     migrations recorded by the call. Applying, rollback and SetStatus of migration reset its baselined flag.
+//   - Migrations with migrating status record their owner, GetLastMigations and GetMigations
+//     set Orphaned of migrations whose owner doesn't exist anymore.
+//   - Every applying, rollback, baselining and status change by SetStatus is appended to migrations event log
+//     with status before and after it, including failed attempts. GetEvents returns events
+//     selected by filter in order they were recorded.
+//   - Lock takes run lock of migrations log table, so only one migrator applies migrations at a time.
+//     Lock waits for the lock until ctx deadline and then returns *LockError describing holder of the lock,
+//     if ctx is cancelled Lock returns ctx.Err().
+//     TryLock takes run lock only if it is free and returns *LockError otherwise.
+//     Lock is released by Unlock or when connection of the holder is lost.
+type Driver interface {
+	Connect(ctx context.Context) error
+	Init(ctx context.Context) error
+	Initialized(ctx context.Context) (bool, error)
+	Up(ctx context.Context, migrations []Migration) ([]Migration, error)
+	Down(ctx context.Context, migrations []Migration) ([]Migration, error)
+	GetLastMigations(ctx context.Context, status MigrationStatus, count int) ([]Migration, error)
+	GetMigations(ctx context.Context) ([]Migration, error)
+	SetChecksum(ctx context.Context, migrationName, checksum string) error
+	SetStatus(ctx context.Context, migration Migration, direction MigrationDirection, status MigrationStatus) error
+	Baseline(ctx context.Context, migrations []Migration) ([]Migration, error)
+	GetEvents(ctx context.Context, filter EventFilter) ([]Event, error)
+	Lock(ctx context.Context) error
+	TryLock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+	Close() error
+}
@@ -0,0 +1,86 @@
+package migrator
+
+import (
+	"os"
+	"os/user"
+	"time"
+)
+
+// Event is a record of migrations event log. Event is appended when migration is applied or rolled back
+// and when its status is set without applying, events are never updated or deleted.
+type Event struct {
+	ID        uint   `db:"id"`
+	Migration string `db:"migration"`
+	// Direction of status set without applying migration is the one passed to SetStatus,
+	// it is empty for events recorded before directions of such changes were introduced.
+	Direction  MigrationDirection `db:"direction"`
+	FromStatus MigrationStatus    `db:"from_status"`
+	ToStatus   MigrationStatus    `db:"to_status"`
+	StartedAt  time.Time          `db:"started_at"`
+	FinishedAt time.Time          `db:"finished_at"`
+	DurationMS int64              `db:"duration_ms"`
+	// OSUser and Hostname identify user and host the migrator was run by.
+	OSUser      string `db:"os_user"`
+	Hostname    string `db:"hostname"`
+	ToolVersion string `db:"tool_version"`
+	// Error is a message of migration error, empty on success.
+	Error string `db:"error"`
+}
+
+// EventFilter selects events returned by Driver.GetEvents, zero fields don't filter events.
+type EventFilter struct {
+	// Migration is a file name of migration.
+	Migration string
+	// Since selects events started at Since or later.
+	Since time.Time
+}
+
+// NewEvent returns event of migration which changed its status from fromStatus to toStatus
+// and was started at started and finished now. err is an error of applying migration.
+func NewEvent(
+	migration Migration,
+	direction MigrationDirection,
+	fromStatus,
+	toStatus MigrationStatus,
+	started time.Time,
+	err error,
+) Event {
+	finished := time.Now()
+	event := Event{
+		Migration:  migration.Name,
+		Direction:  direction,
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		// Время хранится в UTC, чтобы события разных хостов сравнивались без учёта часовых поясов.
+		StartedAt:  started.UTC(),
+		FinishedAt: finished.UTC(),
+		DurationMS: finished.Sub(started).Milliseconds(),
+		OSUser:     currentOSUser(),
+		Hostname:   CurrentOwner().Host,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	return event
+}
+
+// currentOSUser returns name of OS user running migrator, or $USER if the user can't be looked up.
+func currentOSUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+
+	return u.Username
+}
+
+// AppliedChecksum returns checksum recorded together with the status of migration after event:
+// Checksum of successfully applied migration, empty otherwise, so recorded checksum is kept.
+func AppliedChecksum(migration Migration, event Event) string {
+	if event.Direction != MigrationDirectionUp || event.Error != "" {
+		return ""
+	}
+
+	return migration.Checksum
+}
@@ -0,0 +1,172 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLocked is matched by errors returned by Driver.Lock if run lock is held by another migrator.
+var ErrLocked = errors.New("another migrator is running")
+
+// LockError is returned by Driver.Lock if run lock wasn't acquired before context was done.
+type LockError struct {
+	// Holder describes process holding the lock, e.g. "pid 42 on host pod-1". It is empty if unknown.
+	Holder string
+	Err    error
+}
+
+func (e *LockError) Error() string {
+	if e.Holder == "" {
+		return ErrLocked.Error()
+	}
+
+	return fmt.Sprintf("%s (%s)", ErrLocked, e.Holder)
+}
+
+func (e *LockError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// lockRetryInterval is an interval between attempts to take run lock in PollLock.
+const lockRetryInterval = 200 * time.Millisecond
+
+// PollLock calls tryLock until it takes the lock or ctx is done, tryLock is called once if wait is false.
+// If the lock is held by another migrator when ctx deadline is exceeded or when wait is false,
+// PollLock returns *LockError with holder of the lock described by holder.
+// If ctx is cancelled, PollLock returns ctx.Err(), so cancellation isn't reported as a held lock.
+// It helps drivers without blocking locks to implement Lock and TryLock and to take locks of migrations.
+func PollLock(
+	ctx context.Context,
+	wait bool,
+	tryLock func(ctx context.Context) (bool, error),
+	holder func(ctx context.Context) string,
+) error {
+	for {
+		locked, err := tryLock(ctx)
+		if err == nil && locked {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("error while take lock: %w", err)
+		}
+
+		if !wait {
+			return lockNotAcquired(ctx, holder)
+		}
+
+		select {
+		case <-ctx.Done():
+			return lockNotAcquired(ctx, holder)
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// lockNotAcquired returns error of PollLock which didn't take the lock: ctx.Err() if ctx was cancelled,
+// *LockError otherwise.
+func lockNotAcquired(ctx context.Context, holder func(ctx context.Context) string) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+
+	return newLockError(ctx, holder)
+}
+
+// newLockError returns LockError with holder of run lock.
+func newLockError(ctx context.Context, holder func(ctx context.Context) string) *LockError {
+	// Контекст ожидания блокировки может быть уже завершён, поэтому владелец запрашивается с отдельным таймаутом.
+	holderCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	return &LockError{Holder: holder(holderCtx), Err: ctx.Err()}
+}
+
+// Owner identifies process applying migrations.
+type Owner struct {
+	PID  int
+	Host string
+}
+
+// CurrentOwner returns owner of current process.
+func CurrentOwner() Owner {
+	// Если имя хоста недоступно, владелец определяется только по pid.
+	host, _ := os.Hostname()
+
+	return Owner{PID: os.Getpid(), Host: host}
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("pid %d on host %s", o.PID, o.Host)
+}
+
+// ownerPrefix starts application names of connections holding locks, see Owner.ApplicationName.
+const ownerPrefix = "gomigrator:"
+
+// ApplicationName returns connection application name identifying owner, e.g. "gomigrator:42@pod-1".
+func (o Owner) ApplicationName() string {
+	return ownerPrefix + strconv.Itoa(o.PID) + "@" + o.Host
+}
+
+// ParseApplicationName returns owner encoded with Owner.ApplicationName.
+func ParseApplicationName(name string) (Owner, bool) {
+	rest, found := strings.CutPrefix(name, ownerPrefix)
+	if !found {
+		return Owner{}, false
+	}
+
+	pid, host, found := strings.Cut(rest, "@")
+	if !found {
+		return Owner{}, false
+	}
+
+	owner := Owner{Host: host}
+	var err error
+	owner.PID, err = strconv.Atoi(pid)
+	if err != nil {
+		return Owner{}, false
+	}
+
+	return owner, true
+}
+
+// ParseOwner returns owner formatted with Owner.String.
+func ParseOwner(s string) (Owner, bool) {
+	rest, found := strings.CutPrefix(s, "pid ")
+	if !found {
+		return Owner{}, false
+	}
+
+	pid, host, found := strings.Cut(rest, " on host ")
+	if !found {
+		return Owner{}, false
+	}
+
+	owner := Owner{Host: host}
+	var err error
+	owner.PID, err = strconv.Atoi(pid)
+	if err != nil {
+		return Owner{}, false
+	}
+
+	return owner, true
+}
+
+// Alive reports whether owner process is running. Only processes of current host can be checked,
+// known is false for owners on other hosts and on platforms without process lookup.
+// Pid of a stopped process may be reused by another process, then owner is reported alive.
+func (o Owner) Alive() (alive, known bool) {
+	if o.PID <= 0 || o.Host != CurrentOwner().Host {
+		return false, false
+	}
+
+	return processAlive(o.PID)
+}
@@ -0,0 +1,65 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollLock(t *testing.T) {
+	held := func(context.Context) (bool, error) {
+		return false, nil
+	}
+	holder := func(context.Context) string {
+		return "pid 7 on host pod-1"
+	}
+
+	t.Run("case free", func(t *testing.T) {
+		attempts := 0
+		err := PollLock(context.Background(), true, func(context.Context) (bool, error) {
+			attempts++
+			return attempts == 2, nil
+		}, holder)
+		require.Nil(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("case no wait", func(t *testing.T) {
+		err := PollLock(context.Background(), false, held, holder)
+		require.ErrorIs(t, err, ErrLocked)
+		require.EqualError(t, err, "another migrator is running (pid 7 on host pod-1)")
+	})
+
+	t.Run("case deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := PollLock(ctx, true, held, holder)
+		require.ErrorIs(t, err, ErrLocked)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("case cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		// Отмена контекста не означает, что блокировку держит другой мигратор.
+		err := PollLock(ctx, true, held, holder)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, errors.Is(err, ErrLocked))
+
+		err = PollLock(ctx, false, held, holder)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, errors.Is(err, ErrLocked))
+	})
+
+	t.Run("case error", func(t *testing.T) {
+		err := PollLock(context.Background(), true, func(context.Context) (bool, error) {
+			return false, errors.New("connection lost")
+		}, holder)
+		require.EqualError(t, err, "error while take lock: connection lost")
+	})
+}
@@ -0,0 +1,121 @@
+// Package migrator contains types shared by migrator application and database drivers.
+// Database backends implement Driver and register it with app.RegisterDriver.
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MigrationHandlerContext is a handler of migration applied in transaction.
+type MigrationHandlerContext func(context.Context, *sqlx.Tx) error
+
+// MigrationHandlerNoTxContext is a handler of migration applied outside of transaction
+// on a dedicated connection.
+type MigrationHandlerNoTxContext func(context.Context, *sqlx.Conn) error
+
+// Migration is a migration file or Go migration merged with its record in migrations log.
+type Migration struct {
+	ID                 *uint           `db:"id"`
+	FilePath           string          `db:"file_path"`
+	Name               string          `db:"file_name"`
+	Status             MigrationStatus `db:"status"`
+	CreateDT           time.Time       `db:"create_dt"`
+	MigrateDT          *time.Time      `db:"migrate_dt"`
+	Checksum           string          `db:"checksum"`
+	UpHandlerContext   MigrationHandlerContext
+	DownHandlerContext MigrationHandlerContext
+	// NoTransaction is set for migrations applied outside of transaction
+	// with UpHandlerNoTxContext and DownHandlerNoTxContext handlers.
+	NoTransaction          bool
+	UpHandlerNoTxContext   MigrationHandlerNoTxContext
+	DownHandlerNoTxContext MigrationHandlerNoTxContext
+	// UpStatements and DownStatements are SQL statements executed by handlers of SQL migration,
+	// they are shown by dry run. Go migrations have no statements.
+	UpStatements   []string
+	DownStatements []string
+	// FileMissing is set for migrations registered in database but absent in migrations dir.
+	FileMissing bool
+	// ChecksumMismatch is set for applied migrations whose file was changed after applying.
+	// Checksum is recorded for SQL migrations only.
+	ChecksumMismatch bool
+	// OwnerPID, OwnerHost and StartedAt describe the migrator which set migrating status of migration.
+	// OwnerPID identifies database session of the migrator (backend pid, connection id),
+	// or its process if database has no sessions.
+	OwnerPID  *int64     `db:"owner_pid"`
+	OwnerHost string     `db:"owner_host"`
+	StartedAt *time.Time `db:"started_at"`
+	// Orphaned is set for migrations with migrating status whose owner doesn't exist anymore,
+	// e.g. migrator was killed while applying migration.
+	Orphaned bool `db:"orphaned"`
+	// DurationMS is a duration in milliseconds of the last applying or rollback of migration.
+	DurationMS *int64 `db:"duration_ms"`
+	// ErrorMessage, ErrorCode and ErrorStatement describe failure of the last applying or rollback
+	// of migration. ErrorCode is SQLSTATE or another error code of database, ErrorStatement is
+	// an index of the failed statement of SQL migration, see StatementError.
+	ErrorMessage   string `db:"error_message"`
+	ErrorCode      string `db:"error_code"`
+	ErrorStatement *int   `db:"error_statement"`
+	// Baselined is set for migrations recorded as migrated by Baseline without applying,
+	// because schema of existing database already contained their changes.
+	Baselined bool `db:"baselined"`
+}
+
+// StatementError is returned by SQL migration handlers when a statement of migration fails.
+type StatementError struct {
+	FilePath string
+	// Line is a number of the line in migration file the statement starts at.
+	Line int
+	// Index is a 1-based number of the statement in up or down section.
+	Index     int
+	Statement string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("%s:%d: statement #%d: %s", e.FilePath, e.Line, e.Index, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// StatementIndex returns index of the failed statement if err wraps StatementError, otherwise nil.
+func StatementIndex(err error) *int {
+	var statementErr *StatementError
+	if !errors.As(err, &statementErr) {
+		return nil
+	}
+
+	index := statementErr.Index
+	return &index
+}
+
+// MigrationStatus is a status of migration recorded in migrations log.
+type MigrationStatus string
+
+const (
+	MigrationStatusUnknown   MigrationStatus = "unknown"
+	MigrationStatusNew       MigrationStatus = "new"
+	MigrationStatusMigrating MigrationStatus = "migrating"
+	MigrationStatusMigrated  MigrationStatus = "migrated"
+	MigrationStatusFailed    MigrationStatus = "failed"
+)
+
+// MigrationDirection is a direction migration is applied in.
+type MigrationDirection string
+
+const (
+	MigrationDirectionUp   MigrationDirection = "up"
+	MigrationDirectionDown MigrationDirection = "down"
+	// MigrationDirectionBaseline is a direction of events of migrations recorded by Baseline.
+	MigrationDirectionBaseline MigrationDirection = "baseline"
+	// MigrationDirectionMark is a direction of events of status set manually without applying migration.
+	MigrationDirectionMark MigrationDirection = "mark"
+	// MigrationDirectionFix is a direction of events of status of interrupted migration resolved by fix.
+	MigrationDirectionFix MigrationDirection = "fix"
+)
@@ -0,0 +1,534 @@
+// Package migratortest provides conformance tests of migrator.Driver implementations.
+package migratortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"github.com/wursta/gomigrator/pkg/migrator"
+)
+
+// lockWait is a time of waiting for run lock held by another driver in lock test.
+const lockWait = 500 * time.Millisecond
+
+// concurrentDrivers is a number of drivers applying the same migrations concurrently in lock test.
+const concurrentDrivers = 4
+
+var errHandler = errors.New("migratortest: handler error")
+
+// NewDriver returns a new not connected driver. Drivers returned by different calls must work
+// with the same database and the same migrations log table.
+type NewDriver func(t *testing.T) migrator.Driver
+
+// Run runs conformance tests of driver contract described in migrator.Driver.
+// Tests create tables and migrations with unique names, so they can be run against a non-empty database.
+// Tables are created with "CREATE TABLE <name> (id INTEGER)" statements.
+func Run(t *testing.T, newDriver NewDriver) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, s *suite)
+	}{
+		{name: "init", test: testInit},
+		{name: "up and down", test: testUpDown},
+		{name: "up failure", test: testUpFailure},
+		{name: "down failure", test: testDownFailure},
+		{name: "no transaction", test: testNoTransaction},
+		{name: "concurrent up", test: testConcurrentUp},
+		{name: "record checksum", test: testSetChecksum},
+		{name: "run lock", test: testLock},
+		{name: "set status", test: testSetStatus},
+		{name: "migration owner", test: testOwner},
+		{name: "event log", test: testEvents},
+		{name: "baseline", test: testBaseline},
+	}
+
+	for _, tt := range tests {
+		testCase := tt
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.test(t, &suite{
+				newDriver: newDriver,
+				prefix:    fmt.Sprintf("migratortest_%d", time.Now().UnixNano()),
+			})
+		})
+	}
+}
+
+type suite struct {
+	newDriver NewDriver
+	// prefix makes names of tables and migrations of the test unique.
+	prefix string
+}
+
+// driver returns connected and initialized driver closed on test cleanup.
+func (s *suite) driver(t *testing.T) migrator.Driver {
+	t.Helper()
+
+	ctx := context.Background()
+	driver := s.newDriver(t)
+	require.Nil(t, driver.Connect(ctx))
+	t.Cleanup(func() {
+		driver.Close()
+	})
+	require.Nil(t, driver.Init(ctx))
+
+	return driver
+}
+
+func (s *suite) table(name string) string {
+	return s.prefix + "_" + name
+}
+
+// migration returns migration creating table in up handler and dropping it in down handler.
+func (s *suite) migration(name string) migrator.Migration {
+	table := s.table(name)
+	return migrator.Migration{
+		FilePath: "./migrations/" + s.prefix + "__" + name + ".sql",
+		Name:     s.prefix + "__" + name + ".sql",
+		UpHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE "+table+" (id INTEGER)")
+			return err
+		},
+		DownHandlerContext: func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE "+table)
+			return err
+		},
+	}
+}
+
+func failingHandler(context.Context, *sqlx.Tx) error {
+	return errHandler
+}
+
+// migrations returns migrations of the test from migrations log.
+func (s *suite) migrations(t *testing.T, driver migrator.Driver) map[string]migrator.Migration {
+	t.Helper()
+
+	all, err := driver.GetMigations(context.Background())
+	require.Nil(t, err)
+
+	migrations := map[string]migrator.Migration{}
+	for _, migration := range all {
+		if strings.HasPrefix(migration.Name, s.prefix) {
+			migrations[migration.Name] = migration
+		}
+	}
+
+	return migrations
+}
+
+func testInit(t *testing.T, s *suite) {
+	driver := s.driver(t)
+
+	// Повторная инициализация не должна падать.
+	require.Nil(t, driver.Init(context.Background()))
+	require.Nil(t, driver.Connect(context.Background()))
+	require.Empty(t, s.migrations(t, driver))
+
+	initialized, err := driver.Initialized(context.Background())
+	require.Nil(t, err)
+	require.True(t, initialized)
+}
+
+func testUpDown(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	migrations := []migrator.Migration{s.migration("foo"), s.migration("bar")}
+
+	applied, err := driver.Up(ctx, migrations)
+	require.Nil(t, err)
+	require.Len(t, applied, 2)
+	for _, migration := range applied {
+		require.Equal(t, migrator.MigrationStatusMigrated, migration.Status)
+	}
+
+	applied, err = driver.Up(ctx, migrations)
+	require.Nil(t, err)
+	require.Empty(t, applied, "applied migrations must be skipped")
+
+	dbMigrations := s.migrations(t, driver)
+	require.Len(t, dbMigrations, 2)
+	for _, migration := range migrations {
+		dbMigration := dbMigrations[migration.Name]
+		require.Equal(t, migrator.MigrationStatusMigrated, dbMigration.Status)
+		require.Equal(t, migration.FilePath, dbMigration.FilePath)
+		require.NotNil(t, dbMigration.ID)
+		require.NotNil(t, dbMigration.MigrateDT)
+	}
+	require.Greater(t, *dbMigrations[migrations[1].Name].ID, *dbMigrations[migrations[0].Name].ID)
+
+	last, err := driver.GetLastMigations(ctx, migrator.MigrationStatusMigrated, 1)
+	require.Nil(t, err)
+	require.Len(t, last, 1)
+	require.Equal(t, migrations[1].Name, last[0].Name, "the latest applied migration must go first")
+
+	rolledBack, err := driver.Down(ctx, migrations[1:])
+	require.Nil(t, err)
+	require.Len(t, rolledBack, 1)
+	require.Equal(t, migrator.MigrationStatusNew, rolledBack[0].Status)
+
+	dbMigrations = s.migrations(t, driver)
+	require.Equal(t, migrator.MigrationStatusNew, dbMigrations[migrations[1].Name].Status)
+	require.Equal(t, migrator.MigrationStatusMigrated, dbMigrations[migrations[0].Name].Status)
+
+	// Откаченная миграция применяется повторно.
+	applied, err = driver.Up(ctx, migrations)
+	require.Nil(t, err)
+	require.Len(t, applied, 1)
+	require.Equal(t, migrations[1].Name, applied[0].Name)
+
+	_, err = driver.Down(ctx, []migrator.Migration{migrations[1], migrations[0]})
+	require.Nil(t, err)
+}
+
+func testUpFailure(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	failing := s.migration("failing")
+	failing.UpHandlerContext = failingHandler
+	migrations := []migrator.Migration{s.migration("foo"), failing, s.migration("bar")}
+
+	applied, err := driver.Up(ctx, migrations)
+	require.ErrorIs(t, err, errHandler)
+	require.Len(t, applied, 1)
+	require.Equal(t, migrations[0].Name, applied[0].Name)
+
+	dbMigrations := s.migrations(t, driver)
+	require.Equal(t, migrator.MigrationStatusMigrated, dbMigrations[migrations[0].Name].Status)
+	require.Equal(t, migrator.MigrationStatusFailed, dbMigrations[failing.Name].Status)
+	require.Contains(t, dbMigrations[failing.Name].ErrorMessage, errHandler.Error())
+	require.NotNil(t, dbMigrations[failing.Name].DurationMS)
+	require.Empty(t, dbMigrations[migrations[0].Name].ErrorMessage)
+	require.NotNil(t, dbMigrations[migrations[0].Name].DurationMS)
+	require.NotContains(t, dbMigrations, migrations[2].Name, "migrations after failed one must not be applied")
+
+	_, err = driver.Down(ctx, migrations[:1])
+	require.Nil(t, err)
+}
+
+func testDownFailure(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	migration := s.migration("foo")
+	_, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+
+	failing := migration
+	failing.DownHandlerContext = failingHandler
+	rolledBack, err := driver.Down(ctx, []migrator.Migration{failing})
+	require.ErrorIs(t, err, errHandler)
+	require.Empty(t, rolledBack)
+
+	status := s.migrations(t, driver)[migration.Name].Status
+	require.Contains(t, []migrator.MigrationStatus{migrator.MigrationStatusMigrated, migrator.MigrationStatusFailed}, status)
+
+	if status == migrator.MigrationStatusMigrated {
+		_, err = driver.Down(ctx, []migrator.Migration{migration})
+		require.Nil(t, err)
+	}
+}
+
+func testNoTransaction(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	migration := s.migration("foo")
+	table := s.table("foo")
+	migration.NoTransaction = true
+	migration.UpHandlerNoTxContext = func(ctx context.Context, conn *sqlx.Conn) error {
+		_, err := conn.ExecContext(ctx, "CREATE TABLE "+table+" (id INTEGER)")
+		return err
+	}
+	migration.DownHandlerNoTxContext = func(ctx context.Context, conn *sqlx.Conn) error {
+		_, err := conn.ExecContext(ctx, "DROP TABLE "+table)
+		return err
+	}
+
+	failing := s.migration("failing")
+	failing.NoTransaction = true
+	failing.UpHandlerNoTxContext = func(context.Context, *sqlx.Conn) error {
+		return errHandler
+	}
+
+	applied, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Len(t, applied, 1)
+
+	_, err = driver.Up(ctx, []migrator.Migration{failing})
+	require.ErrorIs(t, err, errHandler)
+
+	dbMigrations := s.migrations(t, driver)
+	require.Equal(t, migrator.MigrationStatusMigrated, dbMigrations[migration.Name].Status)
+	require.Equal(t, migrator.MigrationStatusFailed, dbMigrations[failing.Name].Status)
+
+	rolledBack, err := driver.Down(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Len(t, rolledBack, 1)
+}
+
+func testConcurrentUp(t *testing.T, s *suite) {
+	ctx := context.Background()
+
+	migrations := []migrator.Migration{s.migration("foo"), s.migration("bar"), s.migration("baz")}
+	calls := make([]atomic.Int32, len(migrations))
+	for i := range migrations {
+		up := migrations[i].UpHandlerContext
+		calls := &calls[i]
+		migrations[i].UpHandlerContext = func(ctx context.Context, tx *sqlx.Tx) error {
+			calls.Add(1)
+			return up(ctx, tx)
+		}
+	}
+
+	drivers := make([]migrator.Driver, concurrentDrivers)
+	for i := range drivers {
+		drivers[i] = s.driver(t)
+	}
+
+	var wg sync.WaitGroup
+	applied := make([][]migrator.Migration, len(drivers))
+	errs := make([]error, len(drivers))
+	for i := range drivers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = drivers[i].Up(ctx, migrations)
+		}(i)
+	}
+	wg.Wait()
+
+	appliedCount := 0
+	for i := range drivers {
+		require.Nil(t, errs[i])
+		appliedCount += len(applied[i])
+	}
+	require.Equal(t, len(migrations), appliedCount, "every migration must be applied once")
+	for i := range calls {
+		require.Equal(t, int32(1), calls[i].Load(), "handler of %s must be called once", migrations[i].Name)
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		_, err := drivers[0].Down(ctx, migrations[i:i+1])
+		require.Nil(t, err)
+	}
+}
+
+func testSetChecksum(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	migration := s.migration("foo")
+	migration.Checksum = "checksum"
+	_, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Equal(t, "checksum", s.migrations(t, driver)[migration.Name].Checksum)
+
+	require.Nil(t, driver.SetChecksum(ctx, migration.Name, "changed"))
+	require.Equal(t, "changed", s.migrations(t, driver)[migration.Name].Checksum)
+
+	_, err = driver.Down(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+}
+
+func testLock(t *testing.T, s *suite) {
+	ctx := context.Background()
+	holder := s.driver(t)
+	waiter := s.driver(t)
+
+	require.Nil(t, holder.Lock(ctx))
+
+	lockCtx, cancel := context.WithTimeout(ctx, lockWait)
+	defer cancel()
+	err := waiter.Lock(lockCtx)
+	require.ErrorIs(t, err, migrator.ErrLocked)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, waiter.TryLock(ctx), migrator.ErrLocked)
+
+	// Миграции применяются и под блокировкой запуска.
+	migration := s.migration("foo")
+	_, err = holder.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	_, err = holder.Down(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+
+	require.Nil(t, holder.Unlock(ctx))
+	require.Nil(t, waiter.TryLock(ctx))
+	require.Nil(t, waiter.Unlock(ctx))
+	require.Nil(t, waiter.Lock(ctx))
+	require.Nil(t, waiter.Unlock(ctx))
+}
+
+func testSetStatus(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	// Статус записывается и для миграции, которой ещё нет в логе.
+	migration := s.migration("foo")
+	require.Nil(t, driver.SetStatus(ctx, migration, migrator.MigrationDirectionMark, migrator.MigrationStatusMigrated))
+	require.Equal(t, migrator.MigrationStatusMigrated, s.migrations(t, driver)[migration.Name].Status)
+
+	applied, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Empty(t, applied, "migration marked as migrated must be skipped")
+
+	require.Nil(t, driver.SetStatus(ctx, migration, migrator.MigrationDirectionFix, migrator.MigrationStatusFailed))
+	require.Equal(t, migrator.MigrationStatusFailed, s.migrations(t, driver)[migration.Name].Status)
+
+	require.Nil(t, driver.SetStatus(ctx, migration, migrator.MigrationDirectionMark, migrator.MigrationStatusNew))
+	require.Equal(t, migrator.MigrationStatusNew, s.migrations(t, driver)[migration.Name].Status)
+
+	// Направление события показывает, какой командой изменён статус.
+	events, err := driver.GetEvents(ctx, migrator.EventFilter{Migration: migration.Name})
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, migrator.MigrationDirectionMark, events[0].Direction)
+	require.Equal(t, migrator.MigrationStatusMigrated, events[0].ToStatus)
+	require.Equal(t, migrator.MigrationDirectionFix, events[1].Direction)
+	require.Equal(t, migrator.MigrationStatusFailed, events[1].ToStatus)
+	require.Equal(t, migrator.MigrationDirectionMark, events[2].Direction)
+	require.Equal(t, migrator.MigrationStatusNew, events[2].ToStatus)
+}
+
+func testOwner(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+	observer := s.driver(t)
+
+	var running migrator.Migration
+	migration := s.migration("foo")
+	migration.NoTransaction = true
+	migration.UpHandlerNoTxContext = func(context.Context, *sqlx.Conn) error {
+		running = s.migrations(t, observer)[migration.Name]
+		return nil
+	}
+
+	// Владелец миграции жив, пока мигратор держит блокировку запуска.
+	require.Nil(t, driver.Lock(ctx))
+	_, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Nil(t, driver.Unlock(ctx))
+
+	require.Equal(t, migrator.MigrationStatusMigrating, running.Status)
+	require.NotNil(t, running.OwnerPID)
+	require.Equal(t, migrator.CurrentOwner().Host, running.OwnerHost)
+	require.NotNil(t, running.StartedAt)
+	require.False(t, running.Orphaned, "migration of running migrator must not be orphaned")
+
+	require.False(t, s.migrations(t, observer)[migration.Name].Orphaned)
+	require.Nil(t, driver.SetStatus(ctx, migration, migrator.MigrationDirectionMark, migrator.MigrationStatusNew))
+}
+
+func testEvents(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+	started := time.Now().Add(-time.Second)
+
+	migration := s.migration("foo")
+	failing := s.migration("failing")
+	failing.UpHandlerContext = failingHandler
+
+	_, err := driver.Up(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	_, err = driver.Down(ctx, []migrator.Migration{migration})
+	require.Nil(t, err)
+	require.Nil(t, driver.SetStatus(ctx, migration, migrator.MigrationDirectionMark, migrator.MigrationStatusFailed))
+	_, err = driver.Up(ctx, []migrator.Migration{failing})
+	require.ErrorIs(t, err, errHandler)
+
+	events, err := driver.GetEvents(ctx, migrator.EventFilter{Migration: migration.Name})
+	require.Nil(t, err)
+	require.Len(t, events, 3, "every applying, rollback and status change must be recorded")
+
+	expected := []struct {
+		direction  migrator.MigrationDirection
+		fromStatus migrator.MigrationStatus
+		toStatus   migrator.MigrationStatus
+	}{
+		{direction: migrator.MigrationDirectionUp, fromStatus: migrator.MigrationStatusNew, toStatus: migrator.MigrationStatusMigrated},
+		{direction: migrator.MigrationDirectionDown, fromStatus: migrator.MigrationStatusMigrated, toStatus: migrator.MigrationStatusNew},
+		{direction: migrator.MigrationDirectionMark, fromStatus: migrator.MigrationStatusNew, toStatus: migrator.MigrationStatusFailed},
+	}
+	for i, event := range events {
+		require.Equal(t, migration.Name, event.Migration)
+		require.Equal(t, expected[i].direction, event.Direction)
+		require.Equal(t, expected[i].fromStatus, event.FromStatus)
+		require.Equal(t, expected[i].toStatus, event.ToStatus)
+		require.Equal(t, migrator.CurrentOwner().Host, event.Hostname)
+		require.False(t, event.StartedAt.Before(started), "event must be started after the test")
+		require.False(t, event.FinishedAt.Before(event.StartedAt))
+		require.Empty(t, event.Error)
+	}
+
+	events, err = driver.GetEvents(ctx, migrator.EventFilter{Migration: failing.Name})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, migrator.MigrationStatusFailed, events[0].ToStatus)
+	require.Contains(t, events[0].Error, errHandler.Error())
+
+	events, err = driver.GetEvents(ctx, migrator.EventFilter{Migration: migration.Name, Since: started})
+	require.Nil(t, err)
+	require.Len(t, events, 3)
+
+	events, err = driver.GetEvents(ctx, migrator.EventFilter{Migration: migration.Name, Since: time.Now().Add(time.Hour)})
+	require.Nil(t, err)
+	require.Empty(t, events)
+
+	require.Nil(t, driver.SetStatus(ctx, failing, migrator.MigrationDirectionMark, migrator.MigrationStatusNew))
+}
+
+func testBaseline(t *testing.T, s *suite) {
+	ctx := context.Background()
+	driver := s.driver(t)
+
+	// Таблица foo не создаётся: считается, что она уже есть в схеме существующей БД.
+	foo := s.migration("foo")
+	foo.Checksum = "checksum"
+	bar := s.migration("bar")
+	_, err := driver.Up(ctx, []migrator.Migration{bar})
+	require.Nil(t, err)
+
+	baselined, err := driver.Baseline(ctx, []migrator.Migration{foo, bar})
+	require.Nil(t, err)
+	require.Len(t, baselined, 1, "migrated migration must be skipped")
+	require.Equal(t, foo.Name, baselined[0].Name)
+	require.Equal(t, migrator.MigrationStatusMigrated, baselined[0].Status)
+	require.True(t, baselined[0].Baselined)
+
+	migrations := s.migrations(t, driver)
+	require.Equal(t, migrator.MigrationStatusMigrated, migrations[foo.Name].Status)
+	require.True(t, migrations[foo.Name].Baselined)
+	require.Equal(t, foo.Checksum, migrations[foo.Name].Checksum)
+	require.False(t, migrations[bar.Name].Baselined)
+
+	applied, err := driver.Up(ctx, []migrator.Migration{foo})
+	require.Nil(t, err)
+	require.Empty(t, applied, "baselined migration must be skipped")
+
+	events, err := driver.GetEvents(ctx, migrator.EventFilter{Migration: foo.Name})
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, migrator.MigrationDirectionBaseline, events[0].Direction)
+	require.Equal(t, migrator.MigrationStatusNew, events[0].FromStatus)
+	require.Equal(t, migrator.MigrationStatusMigrated, events[0].ToStatus)
+
+	// Изменение статуса сбрасывает признак baselined.
+	require.Nil(t, driver.SetStatus(ctx, foo, migrator.MigrationDirectionMark, migrator.MigrationStatusNew))
+	require.False(t, s.migrations(t, driver)[foo.Name].Baselined)
+	_, err = driver.Up(ctx, []migrator.Migration{foo})
+	require.Nil(t, err)
+
+	_, err = driver.Down(ctx, []migrator.Migration{foo, bar})
+	require.Nil(t, err)
+}
@@ -0,0 +1,84 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Options are common options of drivers.
+type Options struct {
+	// Logger receives progress of applying migrations. Nothing is logged by default.
+	Logger *slog.Logger
+	// DB is an existing connection pool used instead of connecting with DSN.
+	// Driver doesn't close DB.
+	DB *sql.DB
+	// ToolVersion is a version of migrator recorded in migrations event log.
+	ToolVersion string
+	// LockTimeout limits waiting for lock of migration held by another migrator,
+	// zero LockTimeout waits until ctx is done.
+	LockTimeout time.Duration
+	// NoWait makes driver fail at once if migration is locked by another migrator.
+	NoWait bool
+}
+
+// Option configures driver.
+type Option func(*Options)
+
+// WithLogger sets logger of driver, nil logger disables logging.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithDB makes driver use existing connection pool instead of connecting with DSN.
+func WithDB(db *sql.DB) Option {
+	return func(o *Options) {
+		o.DB = db
+	}
+}
+
+// WithToolVersion sets version of migrator recorded in migrations event log.
+func WithToolVersion(version string) Option {
+	return func(o *Options) {
+		o.ToolVersion = version
+	}
+}
+
+// WithLockTimeout limits waiting for lock of migration held by another migrator.
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.LockTimeout = timeout
+	}
+}
+
+// WithNoWait makes driver fail at once if migration is locked by another migrator.
+func WithNoWait(noWait bool) Option {
+	return func(o *Options) {
+		o.NoWait = noWait
+	}
+}
+
+// NewOptions returns options of driver with opts applied.
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Logger == nil {
+		options.Logger = slog.New(discardHandler{})
+	}
+
+	return options
+}
+
+// discardHandler drops all log records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
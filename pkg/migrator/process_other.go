@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package migrator
+
+// processAlive can't check processes on this platform.
+func processAlive(int) (alive, known bool) {
+	return false, false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package migrator
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive checks process by sending signal 0 to it.
+func processAlive(pid int) (alive, known bool) {
+	err := syscall.Kill(pid, 0)
+	switch {
+	case err == nil, errors.Is(err, syscall.EPERM):
+		// Процесс другого пользователя существует, но сигнал ему отправить нельзя.
+		return true, true
+	case errors.Is(err, syscall.ESRCH):
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package migrator
+
+import "os"
+
+// processAlive checks process by opening it, opening fails if the process doesn't exist.
+func processAlive(pid int) (alive, known bool) {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false, true
+	}
+	process.Release()
+
+	return true, true
+}